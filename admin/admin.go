@@ -0,0 +1,367 @@
+// Package admin serves the JSON api for managing the balancer's pool and
+// algorithm at runtime, on a listener separate from the proxied traffic.
+package admin
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// API exposes the balancer for runtime management, every request needs the
+// bearer token when one is set, client certificates are checked by the listener
+type API struct {
+	Balancer *balancer.Balancer
+	Token    string
+	Reloader Reloader //reloads the config file, nil disables the reload routes
+}
+
+// outcome of the config reloads so far
+type ReloadStatus struct {
+	Reloads    uint64     `json:"reloads"`               //reloads that were applied
+	Failures   uint64     `json:"failures"`              //reloads rejected because the new config was invalid
+	LastReload *time.Time `json:"last_reload,omitempty"` //nil before the first reload
+	LastStatus string     `json:"last_status"`           //none, ok or failed
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Reloader reloads the config of the running balancer, implemented by config.Reloader
+type Reloader interface {
+	Reload() error
+	Status() ReloadStatus
+}
+
+// New creates the admin api for the balancer
+func New(lb *balancer.Balancer, token string) *API {
+	return &API{Balancer: lb, Token: token}
+}
+
+// server as listed by the api
+type serverInfo struct {
+	Address          string  `json:"address"`
+	Healthy          bool    `json:"healthy"`
+	Enabled          bool    `json:"enabled"`
+	Draining         bool    `json:"draining"`
+	Ejected          bool    `json:"ejected"`
+	Breaker          string  `json:"breaker"`
+	Weight           int     `json:"weight"`
+	Connections      int     `json:"connections"`
+	SlowStartPercent float64 `json:"slow_start_percent"`
+}
+
+// Handler returns the admin routes behind the token check
+//
+//	GET    /servers                    list the servers
+//	POST   /servers                    add a server, {"address": "...", "weight": 1}
+//	DELETE /servers?address=           remove a server at once
+//	PUT    /servers/weight?address=    set the weight, {"weight": 3}
+//	POST   /servers/drain?address=     drain a server, optional &timeout=<seconds>
+//	POST   /servers/enable?address=    put a server back into rotation
+//	POST   /servers/disable?address=   take a server out of rotation
+//	GET    /algorithm                  get the algorithm
+//	PUT    /algorithm                  set the algorithm, {"algorithm": "..."}
+//	GET    /reload                     get the reload counters and last status
+//	POST   /reload                     reload the config file
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/servers", methods{
+		http.MethodGet:    a.listServers,
+		http.MethodPost:   a.addServer,
+		http.MethodDelete: a.removeServer,
+	})
+	mux.Handle("/servers/weight", methods{http.MethodPut: a.setWeight})
+	mux.Handle("/servers/drain", methods{http.MethodPost: a.drainServer})
+	mux.Handle("/servers/enable", methods{http.MethodPost: a.enableServer(true)})
+	mux.Handle("/servers/disable", methods{http.MethodPost: a.enableServer(false)})
+	mux.Handle("/algorithm", methods{http.MethodGet: a.getAlgorithm, http.MethodPut: a.setAlgorithm})
+	mux.Handle("/reload", methods{http.MethodGet: a.getReloadStatus, http.MethodPost: a.reload})
+	return a.authorize(mux)
+}
+
+// handlers of one path by request method, other methods get a 405
+type methods map[string]http.HandlerFunc
+
+func (m methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := m[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+
+	allowed := make([]string, 0, len(m))
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+}
+
+// rejecting requests without the bearer token, compared in constant time
+func (a *API) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *API) listServers(w http.ResponseWriter, r *http.Request) {
+	slowStart := a.Balancer.GetSlowStart()
+	servers := a.Balancer.GetServers()
+
+	infos := make([]serverInfo, 0, len(servers))
+	for _, server := range servers {
+		infos = append(infos, serverInfo{
+			Address:          server.Address,
+			Healthy:          server.IsServerHealthy(),
+			Enabled:          !server.IsDisabled(),
+			Draining:         server.IsDraining(),
+			Ejected:          server.IsServerEjected(),
+			Breaker:          server.GetBreakerState().String(),
+			Weight:           server.GetWeight(),
+			Connections:      server.GetConnectionCount(),
+			SlowStartPercent: server.GetSlowStartRamp(slowStart) * 100,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"servers": infos})
+}
+
+func (a *API) addServer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address string `json:"address"`
+		Weight  int    `json:"weight"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := validateAddress(req.Address); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Weight < 0 {
+		writeError(w, http.StatusBadRequest, "weight must not be negative")
+		return
+	}
+	server, err := balancer.NewServer(req.Address)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Weight > 0 {
+		server.Weight = req.Weight
+	}
+	//the server starts unhealthy and joins the rotation after its first health check
+	if err := a.Balancer.AddServer(server); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	log.Printf("Admin added server %s", server.Address)
+	writeJSON(w, http.StatusCreated, map[string]string{"address": server.Address})
+}
+
+func (a *API) removeServer(w http.ResponseWriter, r *http.Request) {
+	server := a.findServer(w, r)
+	if server == nil {
+		return
+	}
+	a.Balancer.RemoveServer(server.Address)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) setWeight(w http.ResponseWriter, r *http.Request) {
+	server := a.findServer(w, r)
+	if server == nil {
+		return
+	}
+	var req struct {
+		Weight int `json:"weight"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Weight <= 0 {
+		writeError(w, http.StatusBadRequest, "weight must be positive")
+		return
+	}
+
+	server.SetWeight(req.Weight)
+	log.Printf("Admin set the weight of server %s to %d", server.Address, req.Weight)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"address": server.Address, "weight": req.Weight})
+}
+
+func (a *API) drainServer(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	var timeout time.Duration
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid timeout")
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	err := a.Balancer.DrainServer(address, timeout)
+	switch {
+	case errors.Is(err, balancer.ErrServerNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, balancer.ErrAlreadyDraining):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]string{"address": address, "status": "draining"})
+	}
+}
+
+// handler putting a server back into rotation or taking it out
+func (a *API) enableServer(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server := a.findServer(w, r)
+		if server == nil {
+			return
+		}
+		if server.SetEnabled(enabled) {
+			log.Printf("Admin set server %s enabled=%v", server.Address, enabled)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"address": server.Address, "enabled": enabled})
+	}
+}
+
+func (a *API) getAlgorithm(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"algorithm": a.Balancer.GetAlgorithm(),
+		"available": balancer.StrategyNames(),
+	})
+}
+
+func (a *API) setAlgorithm(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Algorithm string `json:"algorithm"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := a.Balancer.SetAlgorithm(req.Algorithm); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"algorithm": req.Algorithm})
+}
+
+func (a *API) getReloadStatus(w http.ResponseWriter, r *http.Request) {
+	if a.Reloader == nil {
+		writeError(w, http.StatusNotFound, "reloading is not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, a.Reloader.Status())
+}
+
+func (a *API) reload(w http.ResponseWriter, r *http.Request) {
+	if a.Reloader == nil {
+		writeError(w, http.StatusNotFound, "reloading is not enabled")
+		return
+	}
+	//a rejected config keeps the running one, the status carries the error
+	status := http.StatusOK
+	if err := a.Reloader.Reload(); err != nil {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, a.Reloader.Status())
+}
+
+// getting the server named by the address query parameter, writing a 404 when it is not in the pool
+func (a *API) findServer(w http.ResponseWriter, r *http.Request) *balancer.Server {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		writeError(w, http.StatusBadRequest, "missing address")
+		return nil
+	}
+	server := a.Balancer.GetServer(address)
+	if server == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("server %s not found", address))
+	}
+	return server
+}
+
+// checking the address is an absolute http or https url
+func validateAddress(address string) error {
+	serverURL, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid server address %s: %v", address, err)
+	}
+	if (serverURL.Scheme != "http" && serverURL.Scheme != "https") || serverURL.Host == "" {
+		return fmt.Errorf("invalid server address %q, expected http://host:port or https://host:port", address)
+	}
+	return nil
+}
+
+// most of a request body that is read
+const maxRequestBytes = 1 << 16
+
+// decoding the json request body, writing a 400 when it is invalid
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// TLSConfig loads the listener certificate, and when a client ca is given
+// requires clients to present a certificate signed by it (mTLS)
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the admin certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the admin client ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in the admin client ca file %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,422 @@
+package admin_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/admin"
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+const testToken = "secret-token"
+
+//Test Helper function
+
+// creates an admin api over two healthy servers
+func createAdmin(t *testing.T) (*balancer.Balancer, *httptest.Server) {
+	var servers []*balancer.Server
+	for _, address := range []string{"http://localhost:8081", "http://localhost:8082"} {
+		server, _ := balancer.NewServer(address)
+		server.SetHealthy(true)
+		servers = append(servers, server)
+	}
+	lb := balancer.NewLoadBalancer(servers, "round-robin")
+
+	api := httptest.NewServer(admin.New(lb, testToken).Handler())
+	t.Cleanup(api.Close)
+	return lb, api
+}
+
+// sends an authorized request with an optional json body, decoding the json response into out
+func doRequest(t *testing.T, method, target string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, _ := json.Marshal(body)
+		reader = bytes.NewReader(encoded)
+	}
+	req, _ := http.NewRequest(method, target, reader)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to make request, %v", err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Failed to decode response, %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAuthorization(t *testing.T) {
+	_, api := createAdmin(t)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{"No token", "", http.StatusUnauthorized},
+		{"Wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"Wrong scheme", "Basic " + testToken, http.StatusUnauthorized},
+		{"Valid token", "Bearer " + testToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, api.URL+"/servers", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to make request, %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestListServers(t *testing.T) {
+	lb, api := createAdmin(t)
+	lb.GetServers()[1].SetWeight(3)
+
+	var list struct {
+		Servers []struct {
+			Address string `json:"address"`
+			Healthy bool   `json:"healthy"`
+			Enabled bool   `json:"enabled"`
+			Weight  int    `json:"weight"`
+		} `json:"servers"`
+	}
+	if code := doRequest(t, http.MethodGet, api.URL+"/servers", nil, &list); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	if len(list.Servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %d", len(list.Servers))
+	}
+	second := list.Servers[1]
+	if second.Address != "http://localhost:8082" || !second.Healthy || !second.Enabled || second.Weight != 3 {
+		t.Errorf("Unexpected server listing %+v", second)
+	}
+}
+
+func TestAddServer(t *testing.T) {
+	lb, api := createAdmin(t)
+
+	tests := []struct {
+		name     string
+		body     interface{}
+		expected int
+	}{
+		{"New server", map[string]interface{}{"address": "http://localhost:8083", "weight": 2}, http.StatusCreated},
+		{"Duplicate", map[string]interface{}{"address": "http://localhost:8081"}, http.StatusConflict},
+		{"Relative address", map[string]interface{}{"address": "localhost:8084"}, http.StatusBadRequest},
+		{"Negative weight", map[string]interface{}{"address": "http://localhost:8085", "weight": -1}, http.StatusBadRequest},
+		{"Unknown field", map[string]interface{}{"addr": "http://localhost:8086"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doRequest(t, http.MethodPost, api.URL+"/servers", tt.body, nil); code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, code)
+			}
+		})
+	}
+
+	added := lb.GetServer("http://localhost:8083")
+	if lb.GetServerCount() != 3 || added == nil || added.GetWeight() != 2 {
+		t.Errorf("Expected the new server in the pool with weight 2, got %d servers", lb.GetServerCount())
+	}
+	if added != nil && added.IsServerHealthy() {
+		t.Errorf("Expected the new server to wait for its first health check")
+	}
+}
+
+func TestRemoveServer(t *testing.T) {
+	lb, api := createAdmin(t)
+
+	target := api.URL + "/servers?address=" + url.QueryEscape("http://localhost:8081")
+	if code := doRequest(t, http.MethodDelete, target, nil, nil); code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", code)
+	}
+	if lb.GetServerCount() != 1 || lb.GetServer("http://localhost:8081") != nil {
+		t.Errorf("Expected the server to be removed")
+	}
+	if code := doRequest(t, http.MethodDelete, target, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a server no longer in the pool, got %d", code)
+	}
+}
+
+func TestSetWeight(t *testing.T) {
+	lb, api := createAdmin(t)
+	target := api.URL + "/servers/weight?address=" + url.QueryEscape("http://localhost:8082")
+
+	if code := doRequest(t, http.MethodPut, target, map[string]int{"weight": 5}, nil); code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", code)
+	}
+	if weight := lb.GetServer("http://localhost:8082").GetWeight(); weight != 5 {
+		t.Errorf("Expected weight 5, got %d", weight)
+	}
+
+	if code := doRequest(t, http.MethodPut, target, map[string]int{"weight": 0}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a zero weight, got %d", code)
+	}
+	missing := api.URL + "/servers/weight?address=" + url.QueryEscape("http://localhost:9999")
+	if code := doRequest(t, http.MethodPut, missing, map[string]int{"weight": 2}, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown server, got %d", code)
+	}
+}
+
+func TestDrainServer(t *testing.T) {
+	lb, api := createAdmin(t)
+	server := lb.GetServer("http://localhost:8081")
+	server.IncrementConnectionCount()
+	target := api.URL + "/servers/drain?address=" + url.QueryEscape(server.Address)
+
+	if code := doRequest(t, http.MethodPost, target, nil, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if !server.IsDraining() {
+		t.Errorf("Expected the server to be draining")
+	}
+	if code := doRequest(t, http.MethodPost, target, nil, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a server already draining, got %d", code)
+	}
+	if code := doRequest(t, http.MethodPost, target+"&timeout=abc", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid timeout, got %d", code)
+	}
+	missing := api.URL + "/servers/drain?address=" + url.QueryEscape("http://localhost:9999")
+	if code := doRequest(t, http.MethodPost, missing, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown server, got %d", code)
+	}
+}
+
+func TestEnableDisableServer(t *testing.T) {
+	lb, api := createAdmin(t)
+	server := lb.GetServer("http://localhost:8081")
+	query := "?address=" + url.QueryEscape(server.Address)
+
+	if code := doRequest(t, http.MethodPost, api.URL+"/servers/disable"+query, nil, nil); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	for i := 0; i < 4; i++ {
+		if lb.GetNextServer() == server {
+			t.Fatalf("Expected the disabled server to receive no requests")
+		}
+	}
+
+	if code := doRequest(t, http.MethodPost, api.URL+"/servers/enable"+query, nil, nil); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	picked := false
+	for i := 0; i < 4; i++ {
+		picked = picked || lb.GetNextServer() == server
+	}
+	if !picked {
+		t.Errorf("Expected the enabled server back in rotation")
+	}
+
+	if code := doRequest(t, http.MethodPost, api.URL+"/servers/disable", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without an address, got %d", code)
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	lb, api := createAdmin(t)
+
+	var current struct {
+		Algorithm string   `json:"algorithm"`
+		Available []string `json:"available"`
+	}
+	if code := doRequest(t, http.MethodGet, api.URL+"/algorithm", nil, &current); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if current.Algorithm != "round-robin" || len(current.Available) == 0 {
+		t.Errorf("Unexpected algorithm response %+v", current)
+	}
+
+	if code := doRequest(t, http.MethodPut, api.URL+"/algorithm", map[string]string{"algorithm": "least-connections"}, nil); code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", code)
+	}
+	if lb.GetAlgorithm() != "least-connections" {
+		t.Errorf("Expected least-connections, got %s", lb.GetAlgorithm())
+	}
+
+	if code := doRequest(t, http.MethodPut, api.URL+"/algorithm", map[string]string{"algorithm": "random"}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown algorithm, got %d", code)
+	}
+	if lb.GetAlgorithm() != "least-connections" {
+		t.Errorf("Expected the algorithm to be kept, got %s", lb.GetAlgorithm())
+	}
+
+	if code := doRequest(t, http.MethodDelete, api.URL+"/algorithm", nil, nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for an unsupported method, got %d", code)
+	}
+}
+
+// reloader failing while fail is set
+type fakeReloader struct {
+	fail   bool
+	status admin.ReloadStatus
+}
+
+func (f *fakeReloader) Reload() error {
+	now := time.Now()
+	f.status.LastReload = &now
+	if f.fail {
+		f.status.Failures++
+		f.status.LastStatus = "failed"
+		f.status.LastError = "invalid config"
+		return errors.New("invalid config")
+	}
+	f.status.Reloads++
+	f.status.LastStatus = "ok"
+	f.status.LastError = ""
+	return nil
+}
+
+func (f *fakeReloader) Status() admin.ReloadStatus {
+	return f.status
+}
+
+func TestReload(t *testing.T) {
+	_, api := createAdmin(t)
+	if code := doRequest(t, http.MethodPost, api.URL+"/reload", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a reloader, got %d", code)
+	}
+
+	reloader := &fakeReloader{status: admin.ReloadStatus{LastStatus: "none"}}
+	withReloader := admin.New(balancer.NewLoadBalancer(nil, "round-robin"), testToken)
+	withReloader.Reloader = reloader
+	api = httptest.NewServer(withReloader.Handler())
+	defer api.Close()
+
+	var status admin.ReloadStatus
+	if code := doRequest(t, http.MethodGet, api.URL+"/reload", nil, &status); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if status.LastStatus != "none" || status.LastReload != nil {
+		t.Errorf("Expected no reload yet, got %+v", status)
+	}
+
+	if code := doRequest(t, http.MethodPost, api.URL+"/reload", nil, &status); code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", code)
+	}
+	if status.Reloads != 1 || status.LastStatus != "ok" {
+		t.Errorf("Expected one successful reload, got %+v", status)
+	}
+
+	reloader.fail = true
+	if code := doRequest(t, http.MethodPost, api.URL+"/reload", nil, &status); code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a rejected config, got %d", code)
+	}
+	if status.Reloads != 1 || status.Failures != 1 || status.LastStatus != "failed" || status.LastError != "invalid config" {
+		t.Errorf("Expected the failed reload in the status, got %+v", status)
+	}
+}
+
+// writes a certificate signed by the parent, or self signed without one, as pem files
+func writeCertificate(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate, %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	validity := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+	}
+
+	caTemplate := validity(1)
+	caTemplate.Subject = pkix.Name{CommonName: "admin ca"}
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	ca, caKey := writeCertificate(t, dir, "ca", caTemplate, nil, nil)
+
+	serverTemplate := validity(2)
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	writeCertificate(t, dir, "server", serverTemplate, ca, caKey)
+
+	clientTemplate := validity(3)
+	clientTemplate.Subject = pkix.Name{CommonName: "operator"}
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	writeCertificate(t, dir, "client", clientTemplate, ca, caKey)
+
+	tlsConfig, err := admin.TLSConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatalf("Failed to create the tls config, %v", err)
+	}
+	lb := balancer.NewLoadBalancer(nil, "round-robin")
+	api := httptest.NewUnstartedServer(admin.New(lb, "").Handler())
+	api.TLS = tlsConfig
+	api.StartTLS()
+	defer api.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, _ := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"))
+
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	if _, err := newClient().Get(api.URL + "/algorithm"); err == nil {
+		t.Errorf("Expected a client without a certificate to be rejected")
+	}
+
+	resp, err := newClient(clientCert).Get(api.URL + "/algorithm")
+	if err != nil {
+		t.Fatalf("Expected the client certificate to be accepted, %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,590 @@
+// Package balancer keeps a pool of backend servers and selects one of them
+// for each request using a pluggable load balancing algorithm.
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// error returned by AddServer for an address that is already in the pool
+var ErrServerExists = errors.New("server is already in the pool")
+
+type Balancer struct {
+	Servers []*Server
+	Current int
+	Mutex   sync.RWMutex
+	Algo    string //name of a registered Strategy
+
+	//consistent hash settings
+	HashKey      string //ip, header, cookie or path
+	HashKeyName  string //header or cookie name
+	VirtualNodes int
+	ring         *hashRing
+
+	//half life of the per server latency ewma used by peak-ewma
+	EWMAHalfLife time.Duration
+
+	//maglev lookup table, rebuilt when the pool or server health changes
+	MaglevTableSize int
+	maglev          atomic.Pointer[maglevTable]
+	maglevMutex     sync.Mutex
+	generation      atomic.Uint64 //bumped by the servers when their availability changes
+
+	//thresholds for ejecting servers that fail live traffic
+	Outlier      OutlierDetection
+	outlierMutex sync.Mutex
+
+	//thresholds for the circuit breaker around each server
+	Breaker CircuitBreaker
+
+	//traffic ramp for servers that just became healthy
+	SlowStart SlowStart
+
+	//time a draining server gets to finish its requests
+	DrainTimeout time.Duration
+
+	//closed when a server is added or removed, nil until someone waits on it
+	poolChanged chan struct{}
+}
+
+const DefaultEWMAHalfLife = 10 * time.Second
+
+// score of a busy server without latency samples, high enough that it only
+// receives more traffic once its first response has been recorded
+const unknownLatencyPenalty = float64(30 * time.Second)
+
+// scale of the smooth weighted round robin weights, so a ramping server can get a fraction of its weight
+const slowStartWeightScale = 100
+
+// NewLoadBalancer creates a balancer over the servers using the named algorithm,
+// see StrategyNames for the available algorithms
+func NewLoadBalancer(server []*Server, algo string) *Balancer {
+	lb := &Balancer{
+		Servers:      server,
+		Current:      0,
+		Algo:         algo,
+		HashKey:      HashKeyIP,
+		VirtualNodes: DefaultVirtualNodes,
+		ring:         newHashRing(server, DefaultVirtualNodes),
+		EWMAHalfLife: DefaultEWMAHalfLife,
+
+		MaglevTableSize: DefaultMaglevTableSize,
+		Outlier:         DefaultOutlierDetection(),
+		Breaker:         DefaultCircuitBreaker(),
+		SlowStart:       DefaultSlowStart(),
+		DrainTimeout:    DefaultDrainTimeout,
+	}
+	for _, s := range server {
+		s.join(&lb.generation)
+	}
+	return lb
+}
+
+// setting the lookup table size used by the maglev algorithm, the key is shared with consistent hash
+func (lb *Balancer) ConfigureMaglev(tableSize int) error {
+	if err := ValidateMaglevTableSize(tableSize); err != nil {
+		return err
+	}
+
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	lb.MaglevTableSize = tableSize
+	lb.maglev.Store(nil)
+	return nil
+}
+
+// setting the request attribute and ring size used by the consistent hash algorithm
+func (lb *Balancer) ConfigureConsistentHash(key, name string, virtualNodes int) error {
+	if err := ValidateHashKey(key, name); err != nil {
+		return err
+	}
+
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	lb.HashKey = key
+	lb.HashKeyName = name
+	lb.VirtualNodes = virtualNodes
+	lb.ring = newHashRing(lb.Servers, virtualNodes)
+	return nil
+}
+
+// selecting a server without a request, consistent hash falls back to an empty key
+func (lb *Balancer) GetNextServer() *Server {
+	return lb.GetNextServerForRequest(nil)
+}
+
+// selecting a server for the request using the configured algorithm
+func (lb *Balancer) GetNextServerForRequest(r *http.Request) *Server {
+	return lb.GetNextServerExcept(r, nil)
+}
+
+// selecting a server for the request like GetNextServerForRequest, passing over the
+// servers skip returns true for before they take a probe slot of a half-open breaker,
+// the first available server that is not skipped is used when the algorithm keeps
+// returning skipped ones
+func (lb *Balancer) GetNextServerExcept(r *http.Request, skip func(*Server) bool) *Server {
+	return lb.nextServer(r, skip, false)
+}
+
+// selecting a server like GetNextServerExcept and counting the request in flight on it
+// in the same step, so a server being drained is either not picked or waited for,
+// the caller calls DecrementConnectionCount on the server once the request completes
+func (lb *Balancer) AcquireServer(r *http.Request, skip func(*Server) bool) *Server {
+	return lb.nextServer(r, skip, true)
+}
+
+// giving back a server from AcquireServer when the request is not sent to it after all,
+// the request stops counting in flight and the probe slot of a half-open breaker is returned
+func (lb *Balancer) ReleaseServer(server *Server) {
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	if server.ConCount > 0 {
+		server.ConCount--
+	}
+	server.releaseProbe(time.Now())
+}
+
+// selecting a server, with acquire the request is counted on it as well
+func (lb *Balancer) nextServer(r *http.Request, skip func(*Server) bool, acquire bool) *Server {
+	lb.Mutex.RLock()
+	algo, cb, ss, count := lb.Algo, lb.Breaker, lb.SlowStart, len(lb.Servers)
+	lb.Mutex.RUnlock()
+
+	strategy, ok := GetStrategy(algo)
+	if !ok {
+		log.Printf("Warning: unknown algorithm: %s, using round robin", algo)
+		strategy = StrategyFunc(func(lb *Balancer, r *http.Request) *Server { return lb.GetNextServerRoundRobin() })
+	}
+	//the built in algorithms ramp servers up themselves, a custom one may return any server
+	custom := ok && !isBuiltinStrategy(algo)
+
+	//a half-open breaker can run out of probe slots between selection and admission,
+	//the algorithm is asked again as it skips the server from then on
+	var ramping *Server
+	for attempts := 0; attempts <= count; attempts++ {
+		server := strategy.Next(lb, r)
+		if server == nil {
+			break
+		}
+		if skip != nil && skip(server) {
+			continue
+		}
+		now := time.Now()
+		//a ramping server passed over once keeps passing for this request
+		if custom && (server == ramping || !server.admitSlowStart(now, ss)) {
+			if ramping == nil {
+				ramping = server
+			}
+			continue
+		}
+		if server.admit(now, cb, acquire) {
+			return server
+		}
+	}
+
+	//the first available server takes the request when the algorithm keeps returning
+	//servers that are skipped or, for a custom algorithm, not available or ramping up
+	if skip != nil || custom {
+		for _, server := range lb.GetServers() {
+			if server != ramping && (skip == nil || !skip(server)) && server.admit(time.Now(), cb, acquire) {
+				return server
+			}
+		}
+	}
+	if ramping != nil && ramping.admit(time.Now(), cb, acquire) {
+		return ramping
+	}
+	return nil
+}
+
+func (lb *Balancer) GetNextServerRoundRobin() *Server {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	if len(lb.Servers) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var ramping *Server //first server passed over while ramping up, used if nobody else takes the request
+	attempts := 0
+	for attempts < len(lb.Servers) {
+		idx := lb.Current % len(lb.Servers)
+		lb.Current++
+
+		server := lb.Servers[idx]
+
+		server.Mutex.Lock()
+		isAvailable := server.available(now)
+		server.Mutex.Unlock()
+
+		if isAvailable {
+			if server.admitSlowStart(now, lb.SlowStart) {
+				return server
+			}
+			if ramping == nil {
+				ramping = server
+			}
+		}
+		attempts++
+	}
+	return ramping
+}
+
+func (lb *Balancer) GetNextServerLL() *Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	var selectedServer *Server
+	minLoad := math.MaxFloat64
+	now := time.Now()
+
+	for _, server := range lb.Servers {
+		server.Mutex.Lock()
+		isAvailable := server.available(now)
+		//a ramping server counts as more loaded than its connections
+		load := float64(server.ConCount+1) / server.slowStartRamp(now, lb.SlowStart)
+		server.Mutex.Unlock()
+
+		if isAvailable && load < minLoad {
+			selectedServer = server
+			minLoad = load
+		}
+	}
+	return selectedServer
+}
+
+// number of random draws p2c makes before falling back to scanning for healthy servers
+const p2cSampleAttempts = 8
+
+// power of two choices, sampling two random healthy servers and picking the one
+// with fewer connections, avoids scanning the whole pool and herding on ties
+func (lb *Balancer) GetNextServerP2C() *Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	n := len(lb.Servers)
+	if n == 0 {
+		return nil
+	}
+
+	var first, second *Server
+	for attempts := 0; attempts < p2cSampleAttempts && second == nil; attempts++ {
+		server := lb.Servers[rand.Intn(n)]
+		if server == first || !server.IsAvailable() {
+			continue
+		}
+		if first == nil {
+			first = server
+		} else {
+			second = server
+		}
+	}
+
+	//mostly unhealthy pool, sample from the healthy servers instead
+	if second == nil {
+		var healthy []*Server
+		for _, server := range lb.Servers {
+			if server.IsAvailable() {
+				healthy = append(healthy, server)
+			}
+		}
+		switch len(healthy) {
+		case 0:
+			return nil
+		case 1:
+			return healthy[0]
+		}
+		i := rand.Intn(len(healthy))
+		j := rand.Intn(len(healthy) - 1)
+		if j >= i {
+			j++
+		}
+		first, second = healthy[i], healthy[j]
+	}
+
+	now := time.Now()
+	if second.rampedLoad(now, lb.SlowStart) < first.rampedLoad(now, lb.SlowStart) {
+		return second
+	}
+	return first
+}
+
+// latency aware selection, scoring every healthy server by its peak ewma
+// latency times (connections+1) and picking the lowest score
+func (lb *Balancer) GetNextServerPeakEWMA() *Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	var selectedServer *Server
+	minScore := math.MaxFloat64
+	now := time.Now()
+
+	for _, server := range lb.Servers {
+		server.Mutex.RLock()
+		isAvailable := server.available(now)
+		activeconnections := server.ConCount
+		latency := server.latencyEWMAAt(now, lb.EWMAHalfLife)
+		hasSamples := !server.latencyUpdate.IsZero()
+		ramp := server.slowStartRamp(now, lb.SlowStart)
+		server.Mutex.RUnlock()
+
+		if !isAvailable {
+			continue
+		}
+
+		score := latency * float64(activeconnections+1)
+		if !hasSamples && activeconnections > 0 {
+			score = unknownLatencyPenalty
+		}
+		if ramp < 1 {
+			//a nanosecond on top so a ramping server without latency still scores above an idle one
+			score = (score + 1) / ramp
+		}
+
+		if score < minScore {
+			selectedServer = server
+			minScore = score
+		}
+	}
+	return selectedServer
+}
+
+// smooth weighted round robin (nginx style), every healthy server gains its
+// weight on each pick and the winner pays back the total, so heavier servers
+// are interleaved through the cycle instead of being picked in bursts
+func (lb *Balancer) GetNextServerWeightedRoundRobin() *Server {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	var selectedServer *Server
+	totalWeight, bestWeight := 0, 0
+	now := time.Now()
+
+	for _, server := range lb.Servers {
+		server.Mutex.Lock()
+		if server.available(now) {
+			weight := server.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			//weights are scaled up so a ramping server can get a fraction of its weight
+			weight = int(math.Ceil(float64(weight*slowStartWeightScale) * server.slowStartRamp(now, lb.SlowStart)))
+			server.currentWeight += weight
+			totalWeight += weight
+
+			if selectedServer == nil || server.currentWeight > bestWeight {
+				selectedServer = server
+				bestWeight = server.currentWeight
+			}
+		}
+		server.Mutex.Unlock()
+	}
+
+	if selectedServer == nil {
+		return nil
+	}
+
+	selectedServer.Mutex.Lock()
+	selectedServer.currentWeight -= totalWeight
+	selectedServer.Mutex.Unlock()
+
+	return selectedServer
+}
+
+// consistent hashing on the configured request attribute, the same key keeps
+// landing on the same server while the pool changes around it
+func (lb *Balancer) GetNextServerConsistentHash(r *http.Request) *Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	return lb.ring.get(requestHashKey(r, lb.HashKey, lb.HashKeyName), lb.SlowStart)
+}
+
+// maglev hashing on the consistent hash request key, O(1) lookups in a
+// prebuilt table that is swapped out whenever the healthy set changes
+func (lb *Balancer) GetNextServerMaglev(r *http.Request) *Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	table := lb.maglev.Load()
+	if table == nil || table.isStale(lb.generation.Load()) {
+		table = lb.rebuildMaglev()
+	}
+	return table.get(requestHashKey(r, lb.HashKey, lb.HashKeyName), lb.SlowStart)
+}
+
+// rebuilding the maglev table once for all waiting requests, caller must hold lb.Mutex
+func (lb *Balancer) rebuildMaglev() *maglevTable {
+	lb.maglevMutex.Lock()
+	defer lb.maglevMutex.Unlock()
+
+	//another request may have rebuilt it while we were waiting
+	generation := lb.generation.Load()
+	if table := lb.maglev.Load(); table != nil && !table.isStale(generation) {
+		return table
+	}
+
+	table := newMaglevTable(lb.Servers, lb.MaglevTableSize, generation)
+	lb.maglev.Store(table)
+	return table
+}
+
+// replacing a maglev table that is in use after the pool changed, caller must hold lb.Mutex
+func (lb *Balancer) refreshMaglev() {
+	if lb.maglev.Load() != nil {
+		lb.maglev.Store(newMaglevTable(lb.Servers, lb.MaglevTableSize, lb.generation.Load()))
+	}
+}
+
+// adding a new server to the pool for dynamic scaling, fails with ErrServerExists
+// when a server that is not draining has the same address
+func (lb *Balancer) AddServer(server *Server) error {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	//checked under the pool lock so two concurrent adds of an address cannot both succeed,
+	//a server draining under the address may be replaced
+	for _, s := range lb.Servers {
+		if s.Address == server.Address && !s.IsDraining() {
+			return fmt.Errorf("%w: %s", ErrServerExists, server.Address)
+		}
+	}
+
+	//a server joining a running pool ramps up once it becomes healthy
+	server.Mutex.Lock()
+	server.rampOnHealthy = !server.IsHealthy
+	server.Mutex.Unlock()
+
+	server.join(&lb.generation)
+	lb.Servers = append(lb.Servers, server)
+	lb.ring = newHashRing(lb.Servers, lb.VirtualNodes)
+	lb.refreshMaglev()
+	lb.notifyPoolChanged()
+	log.Printf("Added server %s", server.Address)
+	return nil
+}
+
+// removing a server from the server pool
+func (lb *Balancer) RemoveServer(address string) {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	for i, server := range lb.Servers {
+		if server.Address == address {
+			//removing server from the pool
+			lb.Servers = append(lb.Servers[:i], lb.Servers[i+1:]...)
+			server.leave(&lb.generation)
+			lb.ring = newHashRing(lb.Servers, lb.VirtualNodes)
+			lb.refreshMaglev()
+			lb.notifyPoolChanged()
+			log.Printf("Removed Server %s from the pool", server.Address)
+			return
+		}
+	}
+	log.Printf("Warning: server not found, %s", address)
+}
+
+// channel that is closed the next time a server is added or removed, get it
+// before reading the pool so no change is missed
+func (lb *Balancer) PoolChanged() <-chan struct{} {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	if lb.poolChanged == nil {
+		lb.poolChanged = make(chan struct{})
+	}
+	return lb.poolChanged
+}
+
+// waking everyone waiting on a pool change, caller must hold the mutex
+func (lb *Balancer) notifyPoolChanged() {
+	if lb.poolChanged != nil {
+		close(lb.poolChanged)
+		lb.poolChanged = nil
+	}
+}
+
+// snapshot of the server pool, safe to range over while the pool changes
+func (lb *Balancer) GetServers() []*Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	servers := make([]*Server, len(lb.Servers))
+	copy(servers, lb.Servers)
+	return servers
+}
+
+// getting the server with the address, nil if it is not in the pool
+func (lb *Balancer) GetServer(address string) *Server {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	for _, server := range lb.Servers {
+		if server.Address == address {
+			return server
+		}
+	}
+	return nil
+}
+
+// total number of servers
+func (lb *Balancer) GetServerCount() int {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	return len(lb.Servers)
+}
+
+// number of healthy servers
+func (lb *Balancer) GetHealthyServerCount() int {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	count := 0
+	for _, server := range lb.Servers {
+		server.Mutex.Lock()
+		if server.IsHealthy {
+			count++
+		}
+		server.Mutex.Unlock()
+	}
+	return count
+}
+
+// returning the current loadbalancing algorithm
+func (lb *Balancer) GetAlgorithm() string {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	return lb.Algo
+}
+
+// Changes the load balancing algorithm, the name must be registered with RegisterStrategy
+func (lb *Balancer) SetAlgorithm(algo string) error {
+	if _, ok := GetStrategy(algo); !ok {
+		log.Printf("Warning: invalid algorithm: %s, keeping the current algorithm %s", algo, lb.GetAlgorithm())
+		return fmt.Errorf("unknown algorithm: %s", algo)
+	}
+
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	lb.Algo = algo
+	log.Printf("Changed algorithm to %s", algo)
+	return nil
+}
@@ -0,0 +1,1627 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+//Test Helper function
+
+// creates a  test HTTP server with customisable responses
+func createMockServer(response string, statusCode int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if r.URL.Path == "/health" {
+			if statusCode == http.StatusOK {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("healthy"))
+			} else {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("unhealthy"))
+			}
+			return
+		}
+		w.WriteHeader(statusCode)
+		w.Write([]byte(response))
+	}))
+}
+
+// creates a set of test servers
+func createTestServers(count int, healthy bool) ([]*Server, []*httptest.Server) {
+	servers := make([]*Server, count)
+	testServers := make([]*httptest.Server, count)
+
+	for i := 0; i < count; i++ {
+		statusCode := http.StatusOK
+		if !healthy {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		testServer := createMockServer(fmt.Sprintf("server %d", i+1), statusCode, 0)
+		testServers[i] = testServer
+
+		serverURL, _ := url.Parse(testServer.URL)
+		servers[i] = &Server{
+			Address:   testServer.URL,
+			IsHealthy: healthy,
+			URL:       serverURL,
+		}
+	}
+	return servers, testServers
+}
+
+// built in algorithms, tests register their own strategies in the same registry
+var builtinStrategies = []string{
+	"consistent-hash", "least-connections", "least-latency", "maglev",
+	"p2c", "peak-ewma", "round-robin", "weighted-round-robin",
+}
+
+// cleanup closes all test servers
+func cleanup(testServers []*httptest.Server) {
+	for _, server := range testServers {
+		server.Close()
+	}
+}
+
+// Test Server struct
+func TestNewServer(t *testing.T) {
+	t.Run("Valid URL", func(t *testing.T) {
+		server, err := NewServer("http://localhost:8081")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if server.Address != "http://localhost:8081" {
+			t.Errorf("Expected address http://localhost:8081, got %s", server.Address)
+		}
+
+		if server.IsHealthy {
+			t.Errorf("Expected server to be unhealthy initially")
+		}
+
+		if server.ConCount != 0 {
+			t.Errorf("Expected concount to be 0, got %d", server.ConCount)
+		}
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := NewServer("invalid-url")
+		if err != nil {
+			t.Errorf("Expected error for invalid URL")
+		}
+	})
+}
+
+func TestServerConnectionManagement(t *testing.T) {
+	server, _ := NewServer("http://localhost:8081")
+
+	//test initial state
+	if server.GetConnectionCount() != 0 {
+		t.Errorf("Expected the initial connection count to be 0, got %d", server.GetConnectionCount())
+	}
+
+	//test increment
+	server.IncrementConnectionCount()
+	if server.GetConnectionCount() != 1 {
+		t.Errorf("Expected connection count to be 1, got %d", server.GetConnectionCount())
+	}
+
+	//test multiple increments
+	server.IncrementConnectionCount()
+	server.IncrementConnectionCount()
+	if server.GetConnectionCount() != 3 {
+		t.Errorf("Expected connection count to be 3, got %d", server.GetConnectionCount())
+	}
+
+	//test decrement
+	server.DecrementConnectionCount()
+	if server.GetConnectionCount() != 2 {
+		t.Errorf("Expected connection count to be 2, got %d", server.GetConnectionCount())
+	}
+
+	//test multiple decrements
+	server.DecrementConnectionCount()
+	server.DecrementConnectionCount()
+	if server.GetConnectionCount() != 0 {
+		t.Errorf("Expected connection count to be 0, got %d", server.GetConnectionCount())
+	}
+
+	//test decrement below 0, should stay at 0
+	server.DecrementConnectionCount()
+	if server.GetConnectionCount() != 0 {
+		t.Errorf("Expected connection count to remain at 0, got %d", server.GetConnectionCount())
+	}
+}
+
+func TestServerHealthManagement(t *testing.T) {
+	server, _ := NewServer("http://localhost:8081")
+
+	//test initial state
+	if server.IsHealthy {
+		t.Errorf("Expected server initial state to be unhealthy")
+	}
+
+	//test setting healthy
+	server.SetHealthy(true)
+	if !server.IsHealthy {
+		t.Errorf("Expected server state to be set to true")
+	}
+
+	//test setting unhealthy
+	server.SetHealthy(false)
+	if server.IsHealthy {
+		t.Errorf("Expected server state to be set to false")
+	}
+}
+
+// Test for Load Balance
+func TestNewLoadBalancer(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	if lb == nil {
+		t.Fatal("Expected Load Balaner to be created")
+	}
+
+	if len(lb.Servers) != 3 {
+		t.Errorf("Expected 3 servers, got %d", len(lb.Servers))
+	}
+
+	if lb.Algo != "round-robin" {
+		t.Errorf("Expected round-robin algorithm, got %s", lb.Algo)
+	}
+
+	if lb.GetServerCount() != 3 {
+		t.Errorf("Expected server count of 3, got %d", lb.GetServerCount())
+	}
+}
+
+func TestRoundRobinBalancing(t *testing.T) {
+	servers, testservers := createTestServers(3, true)
+	defer cleanup(testservers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	//testing basic round robin rotation
+	firstServer := lb.GetNextServer()
+	secondServer := lb.GetNextServer()
+	thirdServer := lb.GetNextServer()
+	fourthServer := lb.GetNextServer()
+
+	if firstServer == nil || secondServer == nil || thirdServer == nil || fourthServer == nil {
+		t.Fatal("Expected servers to be returned")
+	}
+
+	//cycle through servers
+	if firstServer.Address == secondServer.Address {
+		t.Errorf("Expected different servers in consecutive calls")
+	}
+
+	if secondServer.Address == thirdServer.Address {
+		t.Errorf("Expected different servers in consecutive calls")
+	}
+
+	if firstServer.Address != fourthServer.Address {
+		t.Errorf("Expected to cycle back to first server")
+	}
+}
+
+func TestLeastConnectionBalancing(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	//set different connection counts
+	servers[0].ConCount = 5
+	servers[1].ConCount = 2
+	servers[2].ConCount = 8
+
+	lb := NewLoadBalancer(servers, "least-connections")
+
+	selectServer := lb.GetNextServer()
+
+	if selectServer == nil {
+		t.Fatal("Expected server to be returned")
+	}
+
+	//should select server with least connections
+	if selectServer.ConCount != 2 {
+		t.Errorf("Expected server with 2 connections, as it has the least connections, got server with %d connections", selectServer.ConCount)
+	}
+}
+
+func TestP2CBalancing(t *testing.T) {
+	servers, testServers := createTestServers(2, true)
+	defer cleanup(testServers)
+
+	servers[0].ConCount = 5
+	servers[1].ConCount = 2
+
+	lb := NewLoadBalancer(servers, "p2c")
+
+	//with two servers both are always sampled, so the less loaded one wins
+	for i := 0; i < 10; i++ {
+		server := lb.GetNextServer()
+		if server == nil {
+			t.Fatal("Expected server to be returned")
+		}
+		if server.Address != servers[1].Address {
+			t.Errorf("Expected server with 2 connections, got server with %d connections", server.ConCount)
+		}
+	}
+
+	//unhealthy servers should never be selected
+	servers[1].SetHealthy(false)
+	for i := 0; i < 10; i++ {
+		if server := lb.GetNextServer(); server == nil || server.Address != servers[0].Address {
+			t.Errorf("Expected the only healthy server to be selected")
+		}
+	}
+
+	servers[0].SetHealthy(false)
+	if server := lb.GetNextServer(); server != nil {
+		t.Errorf("Expected no server when all servers are unhealthy")
+	}
+}
+
+func TestP2CSpreadsTies(t *testing.T) {
+	servers := createBenchmarkServers(5)
+	for _, server := range servers {
+		server.ConCount = 0
+	}
+	lb := NewLoadBalancer(servers, "p2c")
+
+	//all servers are idle, ties should not herd onto the first server
+	distribution := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		distribution[lb.GetNextServer().Address]++
+	}
+
+	if len(distribution) != 5 {
+		t.Errorf("Expected requests spread amongst 5 servers, got %d", len(distribution))
+	}
+}
+
+func TestPeakEWMABalancing(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "peak-ewma")
+
+	servers[0].RecordLatency(50*time.Millisecond, lb.EWMAHalfLife)
+	servers[1].RecordLatency(10*time.Millisecond, lb.EWMAHalfLife)
+	servers[2].RecordLatency(30*time.Millisecond, lb.EWMAHalfLife)
+
+	//the fastest server should be selected
+	if server := lb.GetNextServer(); server == nil || server.Address != servers[1].Address {
+		t.Fatalf("Expected the lowest latency server to be selected")
+	}
+
+	//10ms x 4 connections scores worse than 30ms x 1
+	servers[1].ConCount = 3
+	if server := lb.GetNextServer(); server == nil || server.Address != servers[2].Address {
+		t.Errorf("Expected connection count to be part of the score")
+	}
+
+	//least-latency is an alias of the same algorithm
+	lb.Algo = "least-latency"
+	if server := lb.GetNextServer(); server == nil || server.Address != servers[2].Address {
+		t.Errorf("Expected least-latency to select the same server as peak-ewma")
+	}
+}
+
+func TestPeakEWMALatencyTracking(t *testing.T) {
+	server, _ := NewServer("http://localhost:8081")
+	halfLife := 10 * time.Second
+
+	if server.GetLatencyEWMA(halfLife) != 0 {
+		t.Errorf("Expected no latency before any samples")
+	}
+
+	server.RecordLatency(10*time.Millisecond, halfLife)
+
+	//a peak replaces the average immediately
+	server.RecordLatency(100*time.Millisecond, halfLife)
+	if ewma := server.GetLatencyEWMA(halfLife); ewma < 99*time.Millisecond {
+		t.Errorf("Expected a latency peak to be picked up immediately, got %v", ewma)
+	}
+
+	//lower samples only pull the average down gradually
+	server.RecordLatency(10*time.Millisecond, halfLife)
+	if ewma := server.GetLatencyEWMA(halfLife); ewma < 90*time.Millisecond {
+		t.Errorf("Expected the average to decay slowly, got %v", ewma)
+	}
+
+	//with a short half life the average decays away once traffic stops
+	shortHalfLife := 10 * time.Millisecond
+	time.Sleep(100 * time.Millisecond)
+	if ewma := server.GetLatencyEWMA(shortHalfLife); ewma > time.Millisecond {
+		t.Errorf("Expected the average to decay towards zero, got %v", ewma)
+	}
+}
+
+func TestPeakEWMABlendsStoredValue(t *testing.T) {
+	server, _ := NewServer("http://localhost:8081")
+	halfLife := 10 * time.Second
+	start := time.Now()
+
+	//one half life after a 100ms sample, a 50ms sample weighs half
+	server.recordLatencyAt(start, 100*time.Millisecond, halfLife)
+	server.recordLatencyAt(start.Add(halfLife), 50*time.Millisecond, halfLife)
+	if ewma := time.Duration(server.latencyEWMA); ewma != 75*time.Millisecond {
+		t.Errorf("Expected the ewma 75ms, got %v", ewma)
+	}
+
+	//two half lives later a 15ms sample weighs three quarters
+	server.recordLatencyAt(start.Add(3*halfLife), 15*time.Millisecond, halfLife)
+	if ewma := time.Duration(server.latencyEWMA); ewma != 30*time.Millisecond {
+		t.Errorf("Expected the ewma 30ms, got %v", ewma)
+	}
+}
+
+func TestWeightedRoundRobinBalancing(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	//weights of 5:1:1 as in the nginx smooth weighted round robin example
+	servers[0].Weight = 5
+	servers[1].Weight = 1
+	servers[2].Weight = 1
+
+	lb := NewLoadBalancer(servers, "weighted-round-robin")
+
+	sequence := make([]string, 7)
+	distribution := make(map[string]int)
+	for i := 0; i < 7; i++ {
+		server := lb.GetNextServer()
+		if server == nil {
+			t.Fatal("Expected server to be returned")
+		}
+		sequence[i] = server.Address
+		distribution[server.Address]++
+	}
+
+	//one full cycle should honour the weights exactly
+	if distribution[servers[0].Address] != 5 || distribution[servers[1].Address] != 1 || distribution[servers[2].Address] != 1 {
+		t.Errorf("Expected 5:1:1 distribution, got %d:%d:%d", distribution[servers[0].Address], distribution[servers[1].Address], distribution[servers[2].Address])
+	}
+
+	//the heavy server should be interleaved, expected sequence is a a b a c a a
+	expected := []string{servers[0].Address, servers[0].Address, servers[1].Address, servers[0].Address, servers[2].Address, servers[0].Address, servers[0].Address}
+	for i := range expected {
+		if sequence[i] != expected[i] {
+			t.Errorf("Expected pick %d to be %s, got %s", i, expected[i], sequence[i])
+		}
+	}
+
+	//unhealthy servers should be skipped
+	servers[0].SetHealthy(false)
+	for i := 0; i < 4; i++ {
+		server := lb.GetNextServer()
+		if server == nil || server.Address == servers[0].Address {
+			t.Errorf("Expected unhealthy server to be skipped")
+		}
+	}
+}
+
+func TestConsistentHashBalancing(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "consistent-hash")
+	if err := lb.ConfigureConsistentHash(HashKeyHeader, "X-User-ID", 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	//same key should always land on the same server
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-ID", "user-42")
+
+	first := lb.GetNextServerForRequest(req)
+	if first == nil {
+		t.Fatal("Expected server to be returned")
+	}
+	for i := 0; i < 10; i++ {
+		if server := lb.GetNextServerForRequest(req); server != first {
+			t.Errorf("Expected the same server for the same key, got %s and %s", first.Address, server.Address)
+		}
+	}
+
+	//an unhealthy owner should hand its keys to another server
+	first.SetHealthy(false)
+	fallback := lb.GetNextServerForRequest(req)
+	if fallback == nil || fallback == first {
+		t.Errorf("Expected a different healthy server when the owner is unhealthy")
+	}
+
+	//and get them back once it recovers
+	first.SetHealthy(true)
+	if server := lb.GetNextServerForRequest(req); server != first {
+		t.Errorf("Expected key to return to its owner after recovery")
+	}
+
+	//invalid settings should be rejected
+	if err := lb.ConfigureConsistentHash(HashKeyCookie, "", 100); err == nil {
+		t.Errorf("Expected error for cookie key without a name")
+	}
+	if err := lb.ConfigureConsistentHash("super-fluous", "", 100); err == nil {
+		t.Errorf("Expected error for unknown hash key")
+	}
+}
+
+func TestConsistentHashKeys(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42?full=true", nil)
+	req.RemoteAddr = "10.0.0.1:54321"
+	req.Header.Set("X-User-ID", "user-42")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	tests := []struct {
+		key, name, expected string
+	}{
+		{HashKeyIP, "", "10.0.0.1"},
+		{HashKeyHeader, "X-User-ID", "user-42"},
+		{HashKeyCookie, "session", "abc"},
+		{HashKeyCookie, "missing", ""},
+		{HashKeyPath, "", "/users/42"},
+	}
+
+	for _, tt := range tests {
+		if got := requestHashKey(req, tt.key, tt.name); got != tt.expected {
+			t.Errorf("Expected %s key %q, got %q", tt.key, tt.expected, got)
+		}
+	}
+}
+
+func TestConsistentHashRemapping(t *testing.T) {
+	//fixed addresses keep the ring, and so the share of each server, deterministic
+	servers := createBenchmarkServers(4)
+
+	lb := NewLoadBalancer(servers, "consistent-hash")
+	if err := lb.ConfigureConsistentHash(HashKeyPath, "", 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	numKeys := 1000
+	requests := make([]*http.Request, numKeys)
+	before := make([]*Server, numKeys)
+	for i := range requests {
+		requests[i] = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/item/%d", i), nil)
+		before[i] = lb.GetNextServerForRequest(requests[i])
+	}
+
+	//removing a server should only move the keys it owned
+	removed := servers[1]
+	lb.RemoveServer(removed.Address)
+
+	moved := 0
+	for i, req := range requests {
+		after := lb.GetNextServerForRequest(req)
+		if after == removed {
+			t.Fatalf("Expected removed server to receive no keys")
+		}
+		if after != before[i] {
+			moved++
+			if before[i] != removed {
+				t.Errorf("Key %d moved from %s although its server was not removed", i, before[i].Address)
+			}
+		}
+	}
+
+	//roughly a quarter of the keys belonged to the removed server
+	if moved < numKeys/8 || moved > numKeys*3/8 {
+		t.Errorf("Expected around %d keys to move, got %d", numKeys/4, moved)
+	}
+
+	//adding it back should restore the original mapping
+	lb.AddServer(removed)
+	for i, req := range requests {
+		if after := lb.GetNextServerForRequest(req); after != before[i] {
+			t.Errorf("Expected key %d to return to %s after re-adding, got %s", i, before[i].Address, after.Address)
+		}
+	}
+}
+
+func TestMaglevBalancing(t *testing.T) {
+	servers := createBenchmarkServers(5)
+
+	lb := NewLoadBalancer(servers, "maglev")
+	if err := lb.ConfigureConsistentHash(HashKeyHeader, "X-User-ID", 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-ID", "user-42")
+
+	first := lb.GetNextServerForRequest(req)
+	if first == nil {
+		t.Fatal("Expected server to be returned")
+	}
+	for i := 0; i < 10; i++ {
+		if server := lb.GetNextServerForRequest(req); server != first {
+			t.Errorf("Expected the same server for the same key")
+		}
+	}
+
+	//every server should own close to an equal share of the table
+	table := lb.maglev.Load()
+	owned := make(map[*Server]int)
+	for _, server := range table.entries {
+		owned[server]++
+	}
+	expected := DefaultMaglevTableSize / len(servers)
+	for _, server := range servers {
+		if owned[server] < expected*95/100 || owned[server] > expected*105/100 {
+			t.Errorf("Expected %s to own around %d entries, got %d", server.Address, expected, owned[server])
+		}
+	}
+
+	//a health flip should rebuild the table without the unhealthy server
+	first.SetHealthy(false)
+	if server := lb.GetNextServerForRequest(req); server == nil || server == first {
+		t.Errorf("Expected a different healthy server when the owner is unhealthy")
+	}
+	first.SetHealthy(true)
+	if server := lb.GetNextServerForRequest(req); server != first {
+		t.Errorf("Expected key to return to its owner after recovery")
+	}
+
+	//table sizes must be prime
+	if err := lb.ConfigureMaglev(65536); err == nil {
+		t.Errorf("Expected error for a table size that is not prime")
+	}
+	if err := lb.ConfigureMaglev(251); err != nil {
+		t.Errorf("Expected no error for a prime table size, got %v", err)
+	}
+}
+
+func TestMaglevRemapping(t *testing.T) {
+	servers := createBenchmarkServers(10)
+
+	lb := NewLoadBalancer(servers, "maglev")
+	if err := lb.ConfigureConsistentHash(HashKeyPath, "", 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	numKeys := 10000
+	requests := make([]*http.Request, numKeys)
+	before := make([]*Server, numKeys)
+	for i := range requests {
+		requests[i] = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/item/%d", i), nil)
+		before[i] = lb.GetNextServerForRequest(requests[i])
+	}
+
+	removed := servers[3]
+	lb.RemoveServer(removed.Address)
+
+	ownedByRemoved, disrupted := 0, 0
+	for i, req := range requests {
+		after := lb.GetNextServerForRequest(req)
+		if after == removed {
+			t.Fatalf("Expected removed server to receive no keys")
+		}
+		if before[i] == removed {
+			ownedByRemoved++
+		} else if after != before[i] {
+			disrupted++
+		}
+	}
+
+	t.Logf("Removing 1 of %d servers moved %d keys it owned and %d keys owned by other servers", len(servers), ownedByRemoved, disrupted)
+
+	//the removed server's keys have to move, maglev keeps the rest mostly in place
+	if ownedByRemoved < numKeys/20 || ownedByRemoved > numKeys*3/20 {
+		t.Errorf("Expected around %d keys on the removed server, got %d", numKeys/10, ownedByRemoved)
+	}
+	if disrupted > numKeys*3/100 {
+		t.Errorf("Expected at most 3%% of the other keys to move, got %d of %d", disrupted, numKeys)
+	}
+}
+
+func TestMaglevTablePerBalancer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/item/1", nil)
+
+	servers := createBenchmarkServers(3)
+	lb := NewLoadBalancer(servers, "maglev")
+	other := NewLoadBalancer(createBenchmarkServers(3), "maglev")
+	lb.GetNextServerForRequest(req)
+	other.GetNextServerForRequest(req)
+
+	//a health change only makes the table of the balancer holding the server stale
+	servers[0].SetHealthy(false)
+	if !lb.maglev.Load().isStale(lb.generation.Load()) {
+		t.Errorf("Expected the table to be stale after one of its servers changed health")
+	}
+	if other.maglev.Load().isStale(other.generation.Load()) {
+		t.Errorf("Expected the table of another balancer to stay valid")
+	}
+	servers[0].SetHealthy(true)
+	lb.GetNextServerForRequest(req)
+
+	//a server removed from the pool no longer touches its table
+	removed := servers[2]
+	lb.RemoveServer(removed.Address)
+	lb.GetNextServerForRequest(req)
+	removed.SetHealthy(false)
+	if lb.maglev.Load().isStale(lb.generation.Load()) {
+		t.Errorf("Expected a removed server to leave the table valid")
+	}
+
+	//taking the last probe slot passes the server over without a rebuild
+	cb := DefaultCircuitBreaker()
+	cb.Cooldown = 20 * time.Millisecond
+	lb.ConfigureCircuitBreaker(cb)
+	lb.ConfigureOutlierDetection(OutlierDetection{})
+	halfOpen := lb.GetNextServerForRequest(req)
+	for i := 0; i < cb.FailureThreshold; i++ {
+		lb.RecordResult(halfOpen, true)
+	}
+	time.Sleep(cb.Cooldown)
+	if server := lb.GetNextServerForRequest(req); server != halfOpen {
+		t.Fatalf("Expected the half-open owner to take the probe request, got %v", server)
+	}
+	table := lb.maglev.Load()
+	if server := lb.GetNextServerForRequest(req); server == nil || server == halfOpen {
+		t.Errorf("Expected another server while the probe is in flight, got %v", server)
+	}
+	if lb.maglev.Load() != table {
+		t.Errorf("Expected the table to be kept when a probe slot is taken")
+	}
+}
+
+func TestOutlierDetectionEjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []bool //true for a failed request
+		ejected bool
+	}{
+		{"Consecutive errors", []bool{true, true, true, true, true}, true},
+		{"Errors interrupted by a success", []bool{true, true, true, true, false, true, true, true, true}, false},
+		{"Error rate", []bool{false, true, false, true, false, true, false, true, false, true}, true},
+		{"Error rate below threshold", []bool{true, false, false, true, false, false, true, false, false, false}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := createBenchmarkServers(4)
+			lb := NewLoadBalancer(servers, "round-robin")
+
+			for _, failed := range tt.results {
+				lb.RecordResult(servers[0], failed)
+			}
+
+			if servers[0].IsServerEjected() != tt.ejected {
+				t.Errorf("Expected ejected to be %v, got %v", tt.ejected, servers[0].IsServerEjected())
+			}
+			//ejection is separate from the active health state
+			if !servers[0].IsServerHealthy() {
+				t.Errorf("Expected ejection to leave the health status alone")
+			}
+		})
+	}
+}
+
+func TestOutlierEjectionSkippedByAlgorithms(t *testing.T) {
+	for _, algo := range builtinStrategies {
+		t.Run(algo, func(t *testing.T) {
+			servers := createBenchmarkServers(4)
+			lb := NewLoadBalancer(servers, algo)
+
+			//warming up cached tables before the ejection
+			lb.GetNextServer()
+
+			ejected := servers[1]
+			for i := 0; i < lb.Outlier.ConsecutiveErrors; i++ {
+				lb.RecordResult(ejected, true)
+			}
+			if !ejected.IsServerEjected() {
+				t.Fatalf("Expected server to be ejected")
+			}
+
+			for i := 0; i < 200; i++ {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/item/%d", i), nil)
+				if server := lb.GetNextServerForRequest(req); server == ejected {
+					t.Fatalf("Expected the ejected server to receive no requests")
+				}
+			}
+		})
+	}
+}
+
+func TestOutlierConcurrentEjection(t *testing.T) {
+	servers := createBenchmarkServers(4)
+	lb := NewLoadBalancer(servers, "round-robin")
+	od := lb.Outlier
+
+	//failures crossing the threshold together each ask for the ejection
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lb.eject(servers[0], "5 consecutive errors", now, od)
+		}()
+	}
+	wg.Wait()
+
+	servers[0].Mutex.RLock()
+	ejections, until := servers[0].ejections, servers[0].ejectedUntil
+	servers[0].Mutex.RUnlock()
+	if ejections != 1 || !until.Equal(now.Add(od.BaseEjectionTime)) {
+		t.Errorf("Expected one ejection for the base time, got %d until %v", ejections, until.Sub(now))
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	servers := createBenchmarkServers(4)
+	lb := NewLoadBalancer(servers, "round-robin")
+	lb.ConfigureCircuitBreaker(CircuitBreaker{})
+
+	//every server fails, only half of the pool may be ejected
+	for _, server := range servers {
+		for i := 0; i < lb.Outlier.ConsecutiveErrors; i++ {
+			lb.RecordResult(server, true)
+		}
+	}
+
+	ejected := 0
+	for _, server := range servers {
+		if server.IsServerEjected() {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("Expected 2 of 4 servers ejected with max ejection percent 50, got %d", ejected)
+	}
+	if lb.GetNextServer() == nil {
+		t.Errorf("Expected the servers that were not ejected to keep serving")
+	}
+}
+
+func TestOutlierEjectionBackoff(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	lb := NewLoadBalancer(servers, "round-robin")
+	server := servers[0]
+
+	var durations []time.Duration
+	for round := 0; round < 3; round++ {
+		start := time.Now()
+		for i := 0; i < lb.Outlier.ConsecutiveErrors; i++ {
+			lb.RecordResult(server, true)
+		}
+		until := server.GetEjectedUntil()
+		durations = append(durations, until.Sub(start))
+
+		//ending the ejection early to fail again
+		server.Mutex.Lock()
+		server.ejectedUntil = time.Now()
+		server.Mutex.Unlock()
+	}
+
+	for i := 1; i < len(durations); i++ {
+		if durations[i] <= durations[i-1] {
+			t.Errorf("Expected ejection time to grow, got %v", durations)
+		}
+	}
+	if durations[0] < lb.Outlier.BaseEjectionTime || durations[0] > lb.Outlier.BaseEjectionTime+time.Second {
+		t.Errorf("Expected first ejection of %v, got %v", lb.Outlier.BaseEjectionTime, durations[0])
+	}
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name        string
+		probeFailed bool
+		expected    BreakerState
+	}{
+		{"Probe succeeds", false, BreakerClosed},
+		{"Probe fails", true, BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := createBenchmarkServers(1)
+			server := servers[0]
+			lb := NewLoadBalancer(servers, "round-robin")
+			cb := DefaultCircuitBreaker()
+			cb.Cooldown = 50 * time.Millisecond
+			lb.ConfigureCircuitBreaker(cb)
+
+			for i := 0; i < cb.FailureThreshold; i++ {
+				lb.RecordResult(server, true)
+			}
+			if server.GetBreakerState() != BreakerOpen {
+				t.Fatalf("Expected breaker to open after %d failures, got %s", cb.FailureThreshold, server.GetBreakerState())
+			}
+			if lb.GetNextServer() != nil {
+				t.Errorf("Expected an open breaker to block requests")
+			}
+
+			//after the cooldown a single probe request is let through
+			time.Sleep(cb.Cooldown)
+			if server.GetBreakerState() != BreakerHalfOpen {
+				t.Fatalf("Expected breaker to be half-open after the cooldown, got %s", server.GetBreakerState())
+			}
+			if lb.GetNextServer() != server {
+				t.Fatalf("Expected a probe request to be let through")
+			}
+			if lb.GetNextServer() != nil {
+				t.Errorf("Expected only %d probe request while half-open", cb.HalfOpenRequests)
+			}
+
+			lb.RecordResult(server, tt.probeFailed)
+			if server.GetBreakerState() != tt.expected {
+				t.Errorf("Expected breaker to be %s after the probe, got %s", tt.expected, server.GetBreakerState())
+			}
+		})
+	}
+}
+
+func TestSkippedServerKeepsProbeSlot(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	halfOpen := servers[0]
+	lb := NewLoadBalancer(servers, "round-robin")
+	cb := DefaultCircuitBreaker()
+	cb.Cooldown = 20 * time.Millisecond
+	lb.ConfigureCircuitBreaker(cb)
+	lb.ConfigureOutlierDetection(OutlierDetection{})
+
+	for i := 0; i < cb.FailureThreshold; i++ {
+		lb.RecordResult(halfOpen, true)
+	}
+	time.Sleep(cb.Cooldown)
+
+	//a retry skipping the half-open server must not use up its only probe slot
+	skip := func(server *Server) bool { return server == halfOpen }
+	for i := 0; i < 4; i++ {
+		if server := lb.GetNextServerExcept(nil, skip); server != servers[1] {
+			t.Fatalf("Expected the server that is not skipped, got %v", server)
+		}
+	}
+	probed := false
+	for i := 0; i < 4 && !probed; i++ {
+		probed = lb.GetNextServer() == halfOpen
+	}
+	if !probed {
+		t.Errorf("Expected the half-open server to still take its probe request")
+	}
+}
+
+func TestReleasedServerReturnsProbeSlot(t *testing.T) {
+	servers := createBenchmarkServers(1)
+	server := servers[0]
+	lb := NewLoadBalancer(servers, "round-robin")
+	cb := DefaultCircuitBreaker()
+	cb.Cooldown = 20 * time.Millisecond
+	lb.ConfigureCircuitBreaker(cb)
+	lb.ConfigureOutlierDetection(OutlierDetection{})
+
+	for i := 0; i < cb.FailureThreshold; i++ {
+		lb.RecordResult(server, true)
+	}
+	time.Sleep(cb.Cooldown)
+
+	if lb.AcquireServer(nil, nil) != server {
+		t.Fatalf("Expected the half-open server to take a probe request")
+	}
+	if lb.AcquireServer(nil, nil) != nil {
+		t.Fatalf("Expected only %d probe request while half-open", cb.HalfOpenRequests)
+	}
+
+	//a request that is never sent gives its probe slot back
+	lb.ReleaseServer(server)
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected no requests in flight after the release, got %d", count)
+	}
+	if lb.AcquireServer(nil, nil) != server {
+		t.Errorf("Expected the released probe slot to be taken again")
+	}
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	servers := createBenchmarkServers(1)
+	lb := NewLoadBalancer(servers, "round-robin")
+	cb := lb.Breaker
+
+	//alternating failures never reach the consecutive threshold but cross the error rate
+	for i := 0; i < cb.MinRequests; i++ {
+		lb.RecordResult(servers[0], i%2 == 1)
+	}
+	if servers[0].GetBreakerState() != BreakerOpen {
+		t.Errorf("Expected breaker to open on a %.0f%% error rate, got %s", cb.ErrorRate*100, servers[0].GetBreakerState())
+	}
+}
+
+func TestCircuitBreakerSkippedByAlgorithms(t *testing.T) {
+	for _, algo := range builtinStrategies {
+		t.Run(algo, func(t *testing.T) {
+			servers := createBenchmarkServers(4)
+			lb := NewLoadBalancer(servers, algo)
+			lb.ConfigureOutlierDetection(OutlierDetection{})
+
+			//warming up cached tables before the breaker opens
+			lb.GetNextServer()
+
+			open := servers[2]
+			for i := 0; i < lb.Breaker.FailureThreshold; i++ {
+				lb.RecordResult(open, true)
+			}
+			if open.GetBreakerState() != BreakerOpen {
+				t.Fatalf("Expected breaker to be open, got %s", open.GetBreakerState())
+			}
+
+			for i := 0; i < 200; i++ {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/item/%d", i), nil)
+				if server := lb.GetNextServerForRequest(req); server == open {
+					t.Fatalf("Expected the server with an open breaker to receive no requests")
+				}
+			}
+		})
+	}
+}
+
+func TestSlowStartRamp(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		mode     string
+		elapsed  time.Duration
+		expected float64
+	}{
+		{"Linear start", SlowStartLinear, 0, 0.1},
+		{"Linear half way", SlowStartLinear, 30 * time.Second, 0.55},
+		{"Linear done", SlowStartLinear, time.Minute, 1},
+		{"Exponential start", SlowStartExponential, 0, 0.1},
+		{"Exponential half way", SlowStartExponential, 30 * time.Second, 0.3162},
+		{"Exponential done", SlowStartExponential, 2 * time.Minute, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{Address: "http://localhost:8081", IsHealthy: true, rampStart: now.Add(-tt.elapsed)}
+			ss := SlowStart{Window: time.Minute, Mode: tt.mode, MinFraction: 0.1}
+
+			if ramp := server.slowStartRamp(now, ss); ramp < tt.expected-0.001 || ramp > tt.expected+0.001 {
+				t.Errorf("Expected ramp %.4f, got %.4f", tt.expected, ramp)
+			}
+		})
+	}
+
+	//without a window, or for a server healthy from the start, there is no ramp
+	server := &Server{Address: "http://localhost:8081", IsHealthy: true}
+	if ramp := server.GetSlowStartRamp(SlowStart{Window: time.Minute, Mode: SlowStartLinear, MinFraction: 0.1}); ramp != 1 {
+		t.Errorf("Expected no ramp for a server without a health transition, got %.2f", ramp)
+	}
+	server.rampStart = now
+	if ramp := server.GetSlowStartRamp(DefaultSlowStart()); ramp != 1 {
+		t.Errorf("Expected no ramp with slow start disabled, got %.2f", ramp)
+	}
+}
+
+func TestSlowStartSkipsFirstHealthCheck(t *testing.T) {
+	ss := SlowStart{Window: time.Hour, Mode: SlowStartLinear, MinFraction: 0.1}
+
+	//servers passing their first health check at boot take full traffic at once
+	booted, _ := NewServer("http://localhost:8081")
+	lb := NewLoadBalancer([]*Server{booted}, "round-robin")
+	lb.ConfigureSlowStart(ss)
+	booted.RecordHealthCheck(true, "status 200", 2, 2)
+	if ramp := booted.GetSlowStartRamp(ss); ramp != 1 {
+		t.Errorf("Expected no ramp after the first health check at boot, got %.2f", ramp)
+	}
+
+	//a server going down and coming back ramps up
+	booted.RecordHealthCheck(false, "status 503", 2, 1)
+	booted.RecordHealthCheck(true, "status 200", 1, 1)
+	if ramp := booted.GetSlowStartRamp(ss); ramp > 0.11 {
+		t.Errorf("Expected a recovered server to ramp up, got %.2f", ramp)
+	}
+
+	//so does a server added to the running pool
+	added, _ := NewServer("http://localhost:8082")
+	lb.AddServer(added)
+	added.RecordHealthCheck(true, "status 200", 2, 2)
+	if ramp := added.GetSlowStartRamp(ss); ramp > 0.11 {
+		t.Errorf("Expected an added server to ramp up, got %.2f", ramp)
+	}
+}
+
+func TestSlowStartRespectedByAlgorithms(t *testing.T) {
+	for _, algo := range builtinStrategies {
+		t.Run(algo, func(t *testing.T) {
+			servers := createBenchmarkServers(2)
+			lb := NewLoadBalancer(servers, algo)
+			lb.ConfigureSlowStart(SlowStart{Window: time.Hour, Mode: SlowStartLinear, MinFraction: 0.1})
+
+			recovering := servers[1]
+			recovering.SetHealthy(false)
+			recovering.SetHealthy(true)
+
+			picks := 0
+			total := 1000
+			for i := 0; i < total; i++ {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/item/%d", i), nil)
+				req.RemoteAddr = fmt.Sprintf("10.0.%d.%d:1234", i/250, i%250)
+				if lb.GetNextServerForRequest(req) == recovering {
+					picks++
+				}
+			}
+
+			//at 10% of full traffic the recovering server gets well under its even share
+			if picks > total/4 {
+				t.Errorf("Expected the ramping server to get under %d of %d requests, got %d", total/4, total, picks)
+			}
+		})
+	}
+}
+
+func TestSlowStartAloneTakesTraffic(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	lb := NewLoadBalancer(servers, "round-robin")
+	lb.ConfigureSlowStart(SlowStart{Window: time.Hour, Mode: SlowStartExponential, MinFraction: 0.1})
+
+	servers[0].SetHealthy(false)
+	servers[1].SetHealthy(false)
+	servers[1].SetHealthy(true)
+
+	//the only available server takes every request even while it ramps
+	for i := 0; i < 20; i++ {
+		if server := lb.GetNextServer(); server != servers[1] {
+			t.Fatalf("Expected the ramping server when no other server is available, got %v", server)
+		}
+	}
+}
+
+func TestDrainServer(t *testing.T) {
+	servers := createBenchmarkServers(3)
+	for _, server := range servers {
+		server.ConCount = 0
+	}
+	lb := NewLoadBalancer(servers, "round-robin")
+	draining := servers[1]
+	draining.IncrementConnectionCount()
+
+	if err := lb.DrainServer(draining.Address, time.Minute); err != nil {
+		t.Fatalf("Failed to drain the server, %v", err)
+	}
+	if !draining.IsDraining() {
+		t.Errorf("Expected the server to be draining")
+	}
+
+	//no new requests, but the server stays in the pool with a request in flight
+	for i := 0; i < 10; i++ {
+		if lb.GetNextServer() == draining {
+			t.Fatalf("Expected the draining server to receive no new requests")
+		}
+	}
+	time.Sleep(3 * drainPollInterval)
+	if lb.GetServerCount() != 3 {
+		t.Errorf("Expected the draining server to stay while a request is in flight, got %d servers", lb.GetServerCount())
+	}
+
+	draining.DecrementConnectionCount()
+	deadline := time.Now().Add(time.Second)
+	for lb.GetServerCount() != 2 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if lb.GetServerCount() != 2 {
+		t.Fatalf("Expected the drained server to be removed, got %d servers", lb.GetServerCount())
+	}
+	for _, server := range lb.GetServers() {
+		if server == draining {
+			t.Errorf("Expected the drained server to be the one removed")
+		}
+	}
+}
+
+func TestAcquireServerCountsBeforeDrain(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	for _, server := range servers {
+		server.ConCount = 0
+	}
+
+	//an algorithm that picked the first server just before it started draining
+	err := RegisterStrategy("always-first", StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+		return lb.Servers[0]
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error registering a strategy, got %v", err)
+	}
+	t.Cleanup(func() { UnregisterStrategy("always-first") })
+	lb := NewLoadBalancer(servers, "always-first")
+
+	acquired := lb.AcquireServer(nil, nil)
+	if acquired != servers[0] || acquired.GetConnectionCount() != 1 {
+		t.Fatalf("Expected the request to be counted on the selected server, got %v", acquired)
+	}
+	if err := lb.DrainServer(acquired.Address, time.Minute); err != nil {
+		t.Fatalf("Failed to drain the server, %v", err)
+	}
+
+	//the draining server is refused at admission, so it never gets a request the drain misses
+	if server := lb.AcquireServer(nil, nil); server != servers[1] {
+		t.Errorf("Expected the other server once the selected one is draining, got %v", server)
+	}
+	if acquired.GetConnectionCount() != 1 {
+		t.Errorf("Expected only the request acquired before the drain, got %d", acquired.GetConnectionCount())
+	}
+	time.Sleep(3 * drainPollInterval)
+	if lb.GetServerCount() != 2 {
+		t.Errorf("Expected the draining server to wait for the acquired request, got %d servers", lb.GetServerCount())
+	}
+
+	acquired.DecrementConnectionCount()
+	deadline := time.Now().Add(time.Second)
+	for lb.GetServerCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if lb.GetServerCount() != 1 {
+		t.Errorf("Expected the drained server to be removed, got %d servers", lb.GetServerCount())
+	}
+}
+
+func TestDrainServerTimeout(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	lb := NewLoadBalancer(servers, "least-connections")
+	lb.ConfigureDrainTimeout(100 * time.Millisecond)
+	servers[0].ConCount = 5
+
+	if err := lb.DrainServer(servers[0].Address, 0); err != nil {
+		t.Fatalf("Failed to drain the server, %v", err)
+	}
+	if err := lb.DrainServer(servers[0].Address, 0); !errors.Is(err, ErrAlreadyDraining) {
+		t.Errorf("Expected ErrAlreadyDraining, got %v", err)
+	}
+	if err := lb.DrainServer("http://unknown:8080", 0); !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("Expected ErrServerNotFound, got %v", err)
+	}
+
+	//the requests never finish, the timeout removes the server anyway
+	deadline := time.Now().Add(time.Second)
+	for lb.GetServerCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if lb.GetServerCount() != 1 || lb.GetServers()[0] != servers[1] {
+		t.Errorf("Expected the draining server to be removed after the timeout")
+	}
+}
+
+func TestAddServerRejectsDuplicate(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	//concurrent adds of one address, only one of them may join the pool
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	added := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server, _ := NewServer("http://10.0.1.1:8080")
+			err := lb.AddServer(server)
+			if err != nil && !errors.Is(err, ErrServerExists) {
+				t.Errorf("Expected ErrServerExists, got %v", err)
+			}
+			if err == nil {
+				mutex.Lock()
+				added++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if added != 1 || lb.GetServerCount() != 3 {
+		t.Errorf("Expected exactly one add to succeed, got %d with %d servers", added, lb.GetServerCount())
+	}
+
+	//a server draining under the address can be replaced
+	servers[0].IncrementConnectionCount()
+	if err := lb.DrainServer(servers[0].Address, time.Minute); err != nil {
+		t.Fatalf("Failed to drain the server, %v", err)
+	}
+	replacement, _ := NewServer(servers[0].Address)
+	if err := lb.AddServer(replacement); err != nil {
+		t.Errorf("Expected a draining server to be replaceable, got %v", err)
+	}
+}
+
+func TestHealthyServerSelection(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	//make only the second server healhty
+	servers[1].IsHealthy = true
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	//should always return the healthy server
+	for i := 0; i < 5; i++ {
+		selectedServer := lb.GetNextServer()
+
+		if selectedServer == nil {
+			t.Fatal("Expected healthy server to be returned")
+		}
+
+		if !selectedServer.IsHealthy {
+			t.Errorf("Expected to get a healthy server")
+		}
+
+		if selectedServer.Address != servers[1].Address {
+			t.Errorf("Expected to get healthy server, got %s ", selectedServer.Address)
+		}
+	}
+}
+
+func TestNoHealthyServers(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	selectedServer := lb.GetNextServer()
+
+	if selectedServer != nil {
+		t.Error("Expected no server when all servers are unhealthy")
+	}
+}
+
+func TestLoadBalancerServerManagement(t *testing.T) {
+	servers, testServers := createTestServers(2, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	//test initial count
+	if lb.GetServerCount() != 2 {
+		t.Errorf("Expected 2 servers, got %d", lb.GetServerCount())
+	}
+
+	//test adding servers
+	newTestServer := createMockServer("new-server", http.StatusOK, 0)
+	defer newTestServer.Close()
+
+	newServerURL, _ := url.Parse(newTestServer.URL)
+	newServer := &Server{
+		Address:   newTestServer.URL,
+		IsHealthy: true,
+		URL:       newServerURL,
+	}
+
+	lb.AddServer(newServer)
+
+	if lb.GetServerCount() != 3 {
+		t.Errorf("Expected 3 servers after adding server, got %d", lb.GetServerCount())
+	}
+
+	//test removing server
+	lb.RemoveServer(newServer.Address)
+
+	if lb.GetServerCount() != 2 {
+		t.Errorf("Expected 2 servers after removing one, got %d", lb.GetServerCount())
+	}
+}
+
+func TestAlgorithmManagement(t *testing.T) {
+	servers, testServers := createTestServers(2, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	//test initial algorithm
+	if lb.GetAlgorithm() != "round-robin" {
+		t.Errorf("Expected round robin, got %s", lb.GetAlgorithm())
+	}
+
+	//test changing algorithm
+	lb.SetAlgorithm("least-connections")
+	if lb.GetAlgorithm() != "least-connections" {
+		t.Errorf("Expected to get least-connections, got %s", lb.GetAlgorithm())
+	}
+
+	//test invalid algorithm, should remain round-robin
+	lb.SetAlgorithm("super-fluous")
+	if lb.GetAlgorithm() != "least-connections" {
+		t.Errorf("Expected to remain least-connections,got %s", lb.GetAlgorithm())
+	}
+}
+
+func TestStrategyRegistry(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	//a custom algorithm that always picks the last server
+	err := RegisterStrategy("always-last", StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+		return lb.Servers[len(lb.Servers)-1]
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error registering a strategy, got %v", err)
+	}
+	t.Cleanup(func() { UnregisterStrategy("always-last") })
+
+	//registering the same name twice should fail
+	if err := RegisterStrategy("always-last", StrategyFunc(func(lb *Balancer, r *http.Request) *Server { return nil })); err == nil {
+		t.Errorf("Expected error registering a duplicate strategy")
+	}
+	if err := RegisterStrategy("round-robin", StrategyFunc(func(lb *Balancer, r *http.Request) *Server { return nil })); err == nil {
+		t.Errorf("Expected error overriding a built in strategy")
+	}
+
+	lb := NewLoadBalancer(servers, "round-robin")
+	if err := lb.SetAlgorithm("always-last"); err != nil {
+		t.Fatalf("Expected registered strategy to be accepted, got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if server := lb.GetNextServer(); server != servers[2] {
+			t.Errorf("Expected the custom strategy to select the last server")
+		}
+	}
+
+	//every built in algorithm should be selectable by name
+	for _, name := range []string{"round-robin", "weighted-round-robin", "least-connections", "p2c", "peak-ewma", "least-latency", "consistent-hash", "maglev"} {
+		if err := lb.SetAlgorithm(name); err != nil {
+			t.Errorf("Expected %s to be a valid algorithm, got %v", name, err)
+		}
+	}
+
+	//unknown names return an error and keep the current algorithm
+	if err := lb.SetAlgorithm("least-connection"); err == nil {
+		t.Errorf("Expected error for unregistered algorithm")
+	}
+	if lb.GetAlgorithm() != "maglev" {
+		t.Errorf("Expected algorithm to remain maglev, got %s", lb.GetAlgorithm())
+	}
+
+	//only registered strategies that are not built in can be removed
+	if err := UnregisterStrategy("round-robin"); err == nil {
+		t.Errorf("Expected error removing a built in strategy")
+	}
+	if err := UnregisterStrategy("always-none"); err == nil {
+		t.Errorf("Expected error removing an unregistered strategy")
+	}
+}
+
+// test concurrent operations
+func TestCustomStrategyChecked(t *testing.T) {
+	servers := createBenchmarkServers(2)
+	err := RegisterStrategy("always-second", StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+		return lb.Servers[1]
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error registering a strategy, got %v", err)
+	}
+	t.Cleanup(func() { UnregisterStrategy("always-second") })
+	lb := NewLoadBalancer(servers, "always-second")
+
+	//a server the custom algorithm returns is passed over when it cannot take requests
+	unavailable := map[string]func(){
+		"unhealthy": func() { servers[1].SetHealthy(false) },
+		"disabled":  func() { servers[1].SetEnabled(false) },
+		"ejected":   func() { lb.eject(servers[1], "5 consecutive errors", time.Now(), lb.Outlier) },
+	}
+	for name, makeUnavailable := range unavailable {
+		servers[1].SetHealthy(true)
+		servers[1].SetEnabled(true)
+		servers[1].Mutex.Lock()
+		servers[1].ejectedUntil = time.Time{}
+		servers[1].Mutex.Unlock()
+
+		makeUnavailable()
+		if server := lb.GetNextServer(); server != servers[0] {
+			t.Errorf("Expected the available server when the returned one is %s, got %v", name, server)
+		}
+	}
+
+	//a ramping server only takes its share
+	servers[1].SetHealthy(false)
+	servers[1].Mutex.Lock()
+	servers[1].ejectedUntil = time.Time{}
+	servers[1].Mutex.Unlock()
+	servers[1].SetHealthy(true)
+	lb.ConfigureSlowStart(SlowStart{Window: time.Hour, Mode: SlowStartLinear, MinFraction: 0.1})
+	picks := 0
+	for i := 0; i < 1000; i++ {
+		if lb.GetNextServer() == servers[1] {
+			picks++
+		}
+	}
+	if picks > 250 {
+		t.Errorf("Expected the ramping server to get under 250 of 1000 requests, got %d", picks)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	servers, testServers := createTestServers(3, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	var wg sync.WaitGroup
+	numRequests := 300
+	results := make([]string, numRequests)
+
+	//Make concurrent Requests
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			server := lb.GetNextServer()
+			if server != nil {
+				results[index] = server.Address
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	//count distributions
+	distribution := make(map[string]int)
+
+	for _, result := range results {
+		if result != "" {
+			distribution[result]++
+		}
+	}
+
+	if len(distribution) != 3 {
+		t.Errorf("Expected requests distributed amongst 3 servers, got %d", len(distribution))
+	}
+
+	//check roughly equal distribution, allow for 30% variance
+	expectedPerServer := numRequests / 3
+	for addr, count := range distribution {
+		if count < expectedPerServer*7/10 || count > expectedPerServer*13/10 {
+			t.Errorf("Server %s got %d requests, expected around %d", addr, count, expectedPerServer)
+		}
+	}
+}
+
+func TestConcurrentConnectionCounting(t *testing.T) {
+	server, _ := NewServer("http://localhost:8081")
+
+	var wg sync.WaitGroup
+	numGoRoutines := 100
+	incrementsPerGoRoutine := 10
+
+	//concurrent increments
+	for i := 0; i < numGoRoutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrementsPerGoRoutine; j++ {
+				server.IncrementConnectionCount()
+			}
+		}()
+	}
+	wg.Wait()
+
+	expected := numGoRoutines * incrementsPerGoRoutine
+	if server.GetConnectionCount() != expected {
+		t.Errorf("Expected %d connections, got %d", expected, server.GetConnectionCount())
+	}
+
+	//concurrent decrements
+	for i := 0; i < numGoRoutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrementsPerGoRoutine; j++ {
+				server.DecrementConnectionCount()
+			}
+		}()
+
+		wg.Wait()
+
+		if server.GetConnectionCount() != 0 {
+			t.Errorf("Expected 0 connections after decrements, got %d", server.GetConnectionCount())
+		}
+	}
+}
+
+//Benchmark tests
+
+func BenchmarkRoundRobinSelection(b *testing.B) {
+	servers, testServers := createTestServers(10, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "round-robin")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lb.GetNextServer()
+		}
+	})
+}
+
+func BenchmarkLeastConnectionsSelection(b *testing.B) {
+	servers, testServers := createTestServers(10, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "least-connections")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lb.GetNextServer()
+		}
+	})
+}
+
+// creates servers without backends for selection benchmarks with large pools
+func createBenchmarkServers(count int) []*Server {
+	servers := make([]*Server, count)
+	for i := 0; i < count; i++ {
+		servers[i], _ = NewServer(fmt.Sprintf("http://10.0.%d.%d:8080", i/256, i%256))
+		servers[i].SetHealthy(true)
+		servers[i].ConCount = i % 7
+	}
+	return servers
+}
+
+func BenchmarkP2CSelection(b *testing.B) {
+	servers, testServers := createTestServers(10, true)
+	defer cleanup(testServers)
+
+	lb := NewLoadBalancer(servers, "p2c")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lb.GetNextServer()
+		}
+	})
+}
+
+// comparing full scan least connections with p2c as the pool grows
+func BenchmarkLargePoolSelection(b *testing.B) {
+	for _, count := range []int{10, 100, 500} {
+		for _, algo := range []string{"least-connections", "p2c"} {
+			b.Run(fmt.Sprintf("%s/%d", algo, count), func(b *testing.B) {
+				lb := NewLoadBalancer(createBenchmarkServers(count), algo)
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						lb.GetNextServer()
+					}
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkMaglevSelection(b *testing.B) {
+	lb := NewLoadBalancer(createBenchmarkServers(500), "maglev")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lb.GetNextServerForRequest(req)
+		}
+	})
+}
+
+func BenchmarkConcurrentConnectionOperations(b *testing.B) {
+	server, _ := NewServer("http://localhost:8081")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			server.IncrementConnectionCount()
+			server.GetConnectionCount()
+			server.DecrementConnectionCount()
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package balancer
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// state of the circuit breaker around a server
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota //requests flow normally
+	BreakerOpen                         //requests are blocked until the cooldown ends
+	BreakerHalfOpen                     //a few probe requests decide whether to close again
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// settings for the per server circuit breaker
+type CircuitBreaker struct {
+	FailureThreshold int           //open after this many failures in a row, 0 disables
+	ErrorRate        float64       //open when this share of requests in the window fail, 0 disables
+	MinRequests      int           //requests needed in the window before the error rate is checked
+	Window           time.Duration //period the error rate is measured over
+	Cooldown         time.Duration //time open before probe requests are let through
+	HalfOpenRequests int           //probe requests allowed at once while half-open
+}
+
+// circuit breaker settings used when none are configured
+func DefaultCircuitBreaker() CircuitBreaker {
+	return CircuitBreaker{
+		FailureThreshold: 5,
+		ErrorRate:        0.5,
+		MinRequests:      20,
+		Window:           10 * time.Second,
+		Cooldown:         10 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+// breaker state kept on each server
+type circuitState struct {
+	state               BreakerState
+	openUntil           time.Time //end of the cooldown while open
+	consecutiveFailures int
+	windowStart         time.Time
+	windowRequests      int
+	windowFailures      int
+	probes              int       //probe requests let through while half-open
+	probesFull          bool      //every probe slot is taken
+	probesUntil         time.Time //unanswered probes are given up on after this time
+}
+
+// setting the circuit breaker thresholds
+func (lb *Balancer) ConfigureCircuitBreaker(cb CircuitBreaker) {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	lb.Breaker = cb
+}
+
+// getting the breaker state, an open breaker past its cooldown reports half-open (thread safe)
+func (s *Server) GetBreakerState() BreakerState {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.breakerState(time.Now())
+}
+
+// effective breaker state, caller must hold the mutex
+func (s *Server) breakerState(now time.Time) BreakerState {
+	if s.circuit.state == BreakerOpen && !now.Before(s.circuit.openUntil) {
+		return BreakerHalfOpen
+	}
+	return s.circuit.state
+}
+
+// checking if the breaker lets a new request through, caller must hold the mutex
+func (s *Server) breakerAllows(now time.Time) bool {
+	switch s.breakerState(now) {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		//a fresh half-open state or a stale batch of probes lets new probes through
+		return !s.circuit.probesFull || !now.Before(s.circuit.probesUntil)
+	}
+	return true
+}
+
+// time the breaker may let requests through again, zero if it already does, caller must hold the mutex
+func (s *Server) breakerAllowsAt(now time.Time) time.Time {
+	if s.breakerAllows(now) {
+		return time.Time{}
+	}
+	if s.circuit.state == BreakerOpen {
+		return s.circuit.openUntil
+	}
+	return s.circuit.probesUntil
+}
+
+// admitting a selected server (thread safe), false when it is not available, e.g.
+// it started draining, or its breaker ran out of probe slots after it was selected,
+// with acquire the request is counted in flight under the same lock that checks for draining
+func (s *Server) admit(now time.Time, cb CircuitBreaker, acquire bool) bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if !s.available(now) || !s.admitBreaker(now, cb) {
+		return false
+	}
+	if acquire {
+		s.ConCount++
+	}
+	return true
+}
+
+// taking a probe slot when the server is half-open, false when the slots ran out
+// after the server was selected, caller must hold the mutex
+func (s *Server) admitBreaker(now time.Time, cb CircuitBreaker) bool {
+	if s.breakerState(now) != BreakerHalfOpen {
+		return s.circuit.state == BreakerClosed
+	}
+
+	if s.circuit.state == BreakerOpen {
+		s.setBreakerState(BreakerHalfOpen, "cooldown ended")
+	}
+	//starting a new batch of probes, unanswered ones are given up on after a cooldown
+	if !now.Before(s.circuit.probesUntil) {
+		s.circuit.probes = 0
+		s.circuit.probesFull = false
+		s.circuit.probesUntil = now.Add(cb.Cooldown)
+	}
+	if s.circuit.probesFull {
+		return false
+	}
+
+	s.circuit.probes++
+	if s.circuit.probes >= max(cb.HalfOpenRequests, 1) {
+		//no more probes, lookups in cached tables pass the server over until the slots reopen
+		s.circuit.probesFull = true
+	}
+	return true
+}
+
+// giving back a probe slot taken by a request that was not sent after all, caller must hold the mutex
+func (s *Server) releaseProbe(now time.Time) {
+	if s.breakerState(now) != BreakerHalfOpen || s.circuit.probes == 0 {
+		return
+	}
+	s.circuit.probes--
+	s.circuit.probesFull = false
+}
+
+// counting a result in the breaker, caller must hold the mutex
+func (s *Server) recordBreakerResult(now time.Time, failed bool, cb CircuitBreaker) {
+	switch s.breakerState(now) {
+	case BreakerOpen:
+		//stray results from requests sent before the breaker opened
+		return
+	case BreakerHalfOpen:
+		//no probe was let through yet, the result is from before the breaker opened
+		if s.circuit.state == BreakerOpen {
+			return
+		}
+		if failed {
+			s.tripBreaker(now, cb, "probe request failed")
+		} else {
+			s.setBreakerState(BreakerClosed, "probe request succeeded")
+		}
+		return
+	}
+
+	if cb.Window > 0 && now.Sub(s.circuit.windowStart) >= cb.Window {
+		s.circuit.windowStart = now
+		s.circuit.windowRequests = 0
+		s.circuit.windowFailures = 0
+	}
+
+	s.circuit.windowRequests++
+	if !failed {
+		s.circuit.consecutiveFailures = 0
+		return
+	}
+	s.circuit.windowFailures++
+	s.circuit.consecutiveFailures++
+
+	if cb.FailureThreshold > 0 && s.circuit.consecutiveFailures >= cb.FailureThreshold {
+		s.tripBreaker(now, cb, fmt.Sprintf("%d consecutive failures", s.circuit.consecutiveFailures))
+		return
+	}
+	if cb.ErrorRate > 0 && s.circuit.windowRequests >= cb.MinRequests {
+		rate := float64(s.circuit.windowFailures) / float64(s.circuit.windowRequests)
+		if rate >= cb.ErrorRate {
+			s.tripBreaker(now, cb, fmt.Sprintf("error rate %.0f%% over %d requests", rate*100, s.circuit.windowRequests))
+		}
+	}
+}
+
+// opening the breaker for the cooldown, caller must hold the mutex
+func (s *Server) tripBreaker(now time.Time, cb CircuitBreaker, reason string) {
+	s.circuit.openUntil = now.Add(cb.Cooldown)
+	s.setBreakerState(BreakerOpen, reason)
+}
+
+// moving the breaker to a new state and starting over its counters, caller must hold the mutex
+func (s *Server) setBreakerState(state BreakerState, reason string) {
+	log.Printf("Circuit breaker for %s %s -> %s: %s", s.Address, s.circuit.state, state, reason)
+
+	s.circuit.state = state
+	s.circuit.consecutiveFailures = 0
+	s.circuit.windowStart = time.Time{}
+	s.circuit.windowRequests = 0
+	s.circuit.windowFailures = 0
+	s.circuit.probes = 0
+	s.circuit.probesFull = false
+	s.circuit.probesUntil = time.Time{}
+	//tables built while the breaker was open already expire when the cooldown ends
+	if state != BreakerHalfOpen {
+		s.changed()
+	}
+}
@@ -0,0 +1,119 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// errors returned by DrainServer
+var (
+	ErrServerNotFound  = errors.New("server not found")
+	ErrAlreadyDraining = errors.New("server is already draining")
+)
+
+// time a draining server gets to finish its requests when none is configured
+const DefaultDrainTimeout = 30 * time.Second
+
+// how often a draining server's connection count is checked
+const drainPollInterval = 50 * time.Millisecond
+
+// setting the time a draining server gets before it is removed with requests still in flight
+func (lb *Balancer) ConfigureDrainTimeout(timeout time.Duration) {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	lb.DrainTimeout = timeout
+}
+
+// draining a server, it gets no new requests and is removed from the pool once its
+// in-flight requests complete or the timeout expires, zero uses the configured timeout
+func (lb *Balancer) DrainServer(address string, timeout time.Duration) error {
+	lb.Mutex.RLock()
+	var server *Server
+	for _, s := range lb.Servers {
+		if s.Address == address {
+			server = s
+			break
+		}
+	}
+	if timeout <= 0 {
+		timeout = lb.DrainTimeout
+	}
+	lb.Mutex.RUnlock()
+
+	if server == nil {
+		return fmt.Errorf("%w: %s", ErrServerNotFound, address)
+	}
+
+	deadline := time.Now().Add(timeout)
+	server.Mutex.Lock()
+	if server.draining {
+		server.Mutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrAlreadyDraining, address)
+	}
+	server.draining = true
+	server.drainDeadline = deadline
+	connections := server.ConCount
+	server.changed()
+	server.Mutex.Unlock()
+
+	log.Printf("Draining server %s with %d requests in flight, timeout %v", address, connections, timeout)
+
+	go lb.awaitDrained(server, deadline)
+	return nil
+}
+
+// removing the draining server once it is idle or the deadline passes
+func (lb *Balancer) awaitDrained(server *Server, deadline time.Time) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		connections := server.GetConnectionCount()
+		if connections == 0 {
+			log.Printf("Server %s drained", server.Address)
+			break
+		}
+		if !time.Now().Before(deadline) {
+			log.Printf("Warning: drain timeout for server %s, removing it with %d requests in flight", server.Address, connections)
+			break
+		}
+		<-ticker.C
+	}
+	lb.removeServer(server)
+}
+
+// removing this exact server from the pool, a server added again under the same
+// address while the old one drained is kept
+func (lb *Balancer) removeServer(server *Server) {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	for i, s := range lb.Servers {
+		if s == server {
+			lb.Servers = append(lb.Servers[:i], lb.Servers[i+1:]...)
+			server.leave(&lb.generation)
+			lb.ring = newHashRing(lb.Servers, lb.VirtualNodes)
+			lb.refreshMaglev()
+			lb.notifyPoolChanged()
+			log.Printf("Removed Server %s from the pool", server.Address)
+			return
+		}
+	}
+}
+
+// checking if the server is draining (thread safe)
+func (s *Server) IsDraining() bool {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.draining
+}
+
+// getting the time a draining server is removed even with requests in flight, zero if it is not draining
+func (s *Server) GetDrainDeadline() time.Time {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.drainDeadline
+}
@@ -0,0 +1,129 @@
+package balancer
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const DefaultVirtualNodes = 100
+
+// request attributes that can be used as the consistent hash key
+const (
+	HashKeyIP     = "ip"
+	HashKeyHeader = "header"
+	HashKeyCookie = "cookie"
+	HashKeyPath   = "path"
+)
+
+// consistent hash ring with virtual nodes
+type hashRing struct {
+	hashes []uint32
+	owners map[uint32]*Server
+}
+
+// building a ring with virtualNodes points for every server in the pool
+func newHashRing(servers []*Server, virtualNodes int) *hashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+
+	ring := &hashRing{
+		hashes: make([]uint32, 0, len(servers)*virtualNodes),
+		owners: make(map[uint32]*Server, len(servers)*virtualNodes),
+	}
+
+	for _, server := range servers {
+		for i := 0; i < virtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + server.Address))
+			//on the rare collision the first server keeps the point
+			if _, exists := ring.owners[hash]; exists {
+				continue
+			}
+			ring.owners[hash] = server
+			ring.hashes = append(ring.hashes, hash)
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+
+	return ring
+}
+
+// walking the ring clockwise from the key and returning the first available server,
+// so a server going down only moves its own keys to the next server on the ring, a
+// ramping server passes some of its keys on and keeps them if nobody else is available
+func (ring *hashRing) get(key string, ss SlowStart) *Server {
+	if ring == nil || len(ring.hashes) == 0 {
+		return nil
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= hash })
+
+	now := time.Now()
+	var ramping *Server
+	visited := make(map[*Server]bool)
+	for i := 0; i < len(ring.hashes); i++ {
+		server := ring.owners[ring.hashes[(start+i)%len(ring.hashes)]]
+		if visited[server] {
+			continue
+		}
+		visited[server] = true
+
+		if !server.IsAvailable() {
+			continue
+		}
+		if server.admitSlowStart(now, ss) {
+			return server
+		}
+		if ramping == nil {
+			ramping = server
+		}
+	}
+	return ramping
+}
+
+// checking the hash key settings from the config
+func ValidateHashKey(key, name string) error {
+	switch key {
+	case HashKeyIP, HashKeyPath:
+		return nil
+	case HashKeyHeader, HashKeyCookie:
+		if name == "" {
+			return fmt.Errorf("hash key %s requires a name", key)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown hash key: %s", key)
+	}
+}
+
+// extracting the hash key from the request
+func requestHashKey(r *http.Request, key, name string) string {
+	if r == nil {
+		return ""
+	}
+
+	switch key {
+	case HashKeyHeader:
+		return r.Header.Get(name)
+	case HashKeyCookie:
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	case HashKeyPath:
+		return r.URL.Path
+	default:
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
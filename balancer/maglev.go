@@ -0,0 +1,134 @@
+package balancer
+
+import (
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"time"
+)
+
+// default lookup table size, must be a prime well above the number of servers
+const DefaultMaglevTableSize = 65537
+
+// maglev lookup table, immutable once built and swapped in as a whole
+type maglevTable struct {
+	entries    []*Server
+	servers    int       //distinct servers in the table
+	generation uint64    //generation of the balancer the table was built at
+	expires    time.Time //first time a server left out of the table becomes available
+}
+
+// populating the lookup table from the healthy servers as described in the maglev paper,
+// every server takes turns claiming the next free slot from its own permutation, the
+// generation is read before the servers so a change while building makes the table stale
+func newMaglevTable(servers []*Server, size int, generation uint64) *maglevTable {
+	table := &maglevTable{generation: generation}
+
+	now := time.Now()
+	var healthy []*Server
+	for _, server := range servers {
+		server.Mutex.RLock()
+		if server.available(now) {
+			healthy = append(healthy, server)
+		} else if at := server.availableAt(now); !at.IsZero() && (table.expires.IsZero() || at.Before(table.expires)) {
+			//ejected servers and open breakers return without a health change, so the table expires with them
+			table.expires = at
+		}
+		server.Mutex.RUnlock()
+	}
+	if len(healthy) == 0 {
+		return table
+	}
+	table.servers = len(healthy)
+
+	m := uint64(size)
+	offsets := make([]uint64, len(healthy))
+	skips := make([]uint64, len(healthy))
+	next := make([]uint64, len(healthy))
+
+	for i, server := range healthy {
+		offsets[i] = fnvHash(server.Address) % m
+		skips[i] = uint64(crc32.ChecksumIEEE([]byte(server.Address)))%(m-1) + 1
+	}
+
+	table.entries = make([]*Server, size)
+	filled := 0
+	for filled < size {
+		for i, server := range healthy {
+			slot := (offsets[i] + next[i]*skips[i]) % m
+			for table.entries[slot] != nil {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % m
+			}
+			table.entries[slot] = server
+			next[i]++
+			filled++
+			if filled == size {
+				break
+			}
+		}
+	}
+	return table
+}
+
+// looking up the server for a key, servers that stopped taking requests since the
+// table was built, like a half-open breaker out of probe slots, pass their keys on to
+// the next server in the table and so does a ramping server for some of its keys,
+// the first available server keeps the key if every ramping server passes
+func (table *maglevTable) get(key string, ss SlowStart) *Server {
+	if len(table.entries) == 0 {
+		return nil
+	}
+	size := uint64(len(table.entries))
+	slot := fnvHash(key) % size
+
+	now := time.Now()
+	var first *Server
+	var passed map[*Server]bool
+	for i := uint64(0); i < size && len(passed) < table.servers; i++ {
+		server := table.entries[(slot+i)%size]
+		if passed[server] {
+			continue
+		}
+		if server.IsAvailable() {
+			if ss.Window <= 0 || server.admitSlowStart(now, ss) {
+				return server
+			}
+			if first == nil {
+				first = server
+			}
+		}
+		if passed == nil {
+			passed = make(map[*Server]bool)
+		}
+		passed[server] = true
+	}
+	return first
+}
+
+// checking the table was built from the current healthy set, generation is the balancer's current one
+func (table *maglevTable) isStale(generation uint64) bool {
+	if table.generation != generation {
+		return true
+	}
+	return !table.expires.IsZero() && !time.Now().Before(table.expires)
+}
+
+// checking the maglev table size from the config
+func ValidateMaglevTableSize(size int) error {
+	if size < 2 {
+		return fmt.Errorf("maglev table size must be a prime, got %d", size)
+	}
+	for i := 2; i*i <= size; i++ {
+		if size%i == 0 {
+			return fmt.Errorf("maglev table size must be a prime, got %d", size)
+		}
+	}
+	return nil
+}
+
+func fnvHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,157 @@
+package balancer
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// settings for ejecting servers that keep failing live traffic, between health checks
+type OutlierDetection struct {
+	ConsecutiveErrors  int           //eject after this many failures in a row, 0 disables
+	ErrorRate          float64       //eject when this share of requests in the window fail, 0 disables
+	MinRequests        int           //requests needed in the window before the error rate is checked
+	Window             time.Duration //period the error rate is measured over
+	BaseEjectionTime   time.Duration //ejection time, multiplied by the number of recent ejections
+	MaxEjectionTime    time.Duration //upper bound for the growing ejection time
+	MaxEjectionPercent int           //share of the pool that can be ejected at once
+}
+
+// outlier detection settings used when none are configured
+func DefaultOutlierDetection() OutlierDetection {
+	return OutlierDetection{
+		ConsecutiveErrors:  5,
+		ErrorRate:          0.5,
+		MinRequests:        10,
+		Window:             10 * time.Second,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionTime:    300 * time.Second,
+		MaxEjectionPercent: 50,
+	}
+}
+
+// setting the outlier detection thresholds
+func (lb *Balancer) ConfigureOutlierDetection(od OutlierDetection) {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	lb.Outlier = od
+}
+
+// recording the result of a proxied request, failed covers connection errors and 5xx
+// responses, servers crossing a threshold are ejected for a growing backoff period
+// and the result is counted by the server's circuit breaker
+func (lb *Balancer) RecordResult(server *Server, failed bool) {
+	lb.Mutex.RLock()
+	od, cb := lb.Outlier, lb.Breaker
+	lb.Mutex.RUnlock()
+
+	now := time.Now()
+	server.Mutex.Lock()
+	reason := server.recordResult(now, failed, od)
+	server.recordBreakerResult(now, failed, cb)
+	server.Mutex.Unlock()
+
+	if reason != "" {
+		lb.eject(server, reason, now, od)
+	}
+}
+
+// ejecting the server unless too much of the pool is already ejected
+func (lb *Balancer) eject(server *Server, reason string, now time.Time, od OutlierDetection) {
+	//one ejection at a time so the max ejection percent holds
+	lb.outlierMutex.Lock()
+	defer lb.outlierMutex.Unlock()
+
+	//failures crossing the threshold at the same time all ask for the ejection, only
+	//the first one counts, ejections only happen under the outlier mutex
+	server.Mutex.RLock()
+	alreadyEjected := now.Before(server.ejectedUntil)
+	server.Mutex.RUnlock()
+	if alreadyEjected {
+		return
+	}
+
+	servers := lb.GetServers()
+	ejected := 0
+	for _, s := range servers {
+		if s != server && s.IsServerEjected() {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > len(servers)*od.MaxEjectionPercent {
+		log.Printf("Warning: not ejecting server %s (%s), %d of %d servers already ejected", server.Address, reason, ejected, len(servers))
+		return
+	}
+
+	server.Mutex.Lock()
+	server.ejections++
+	duration := od.BaseEjectionTime * time.Duration(server.ejections)
+	if od.MaxEjectionTime > 0 && duration > od.MaxEjectionTime {
+		duration = od.MaxEjectionTime
+	}
+	server.ejectedUntil = now.Add(duration)
+	server.resetOutlierWindow(now)
+	server.changed()
+	server.Mutex.Unlock()
+
+	log.Printf("Warning: ejected server %s for %v: %s", server.Address, duration, reason)
+}
+
+// counting the result and returning why the server should be ejected, empty if it
+// should not, caller must hold the mutex
+func (s *Server) recordResult(now time.Time, failed bool, od OutlierDetection) string {
+	//an ejected server only gets stray requests that were already on their way
+	if now.Before(s.ejectedUntil) {
+		return ""
+	}
+
+	if od.Window > 0 && now.Sub(s.windowStart) >= od.Window {
+		//a clean window earns back one ejection so the backoff shrinks again
+		if s.windowErrors == 0 && s.ejections > 0 {
+			s.ejections--
+		}
+		s.resetOutlierWindow(now)
+	}
+
+	s.windowRequests++
+	if !failed {
+		s.consecutiveErrors = 0
+		return ""
+	}
+	s.windowErrors++
+	s.consecutiveErrors++
+
+	if od.ConsecutiveErrors > 0 && s.consecutiveErrors >= od.ConsecutiveErrors {
+		return fmt.Sprintf("%d consecutive errors", s.consecutiveErrors)
+	}
+	if od.ErrorRate > 0 && s.windowRequests >= od.MinRequests {
+		rate := float64(s.windowErrors) / float64(s.windowRequests)
+		if rate >= od.ErrorRate {
+			return fmt.Sprintf("error rate %.0f%% over %d requests", rate*100, s.windowRequests)
+		}
+	}
+	return ""
+}
+
+// starting a new error rate window, caller must hold the mutex
+func (s *Server) resetOutlierWindow(now time.Time) {
+	s.windowStart = now
+	s.windowRequests = 0
+	s.windowErrors = 0
+	s.consecutiveErrors = 0
+}
+
+// checking if the server is ejected by outlier detection (thread safe)
+func (s *Server) IsServerEjected() bool {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return time.Now().Before(s.ejectedUntil)
+}
+
+// getting the time the current ejection ends, zero if the server was never ejected
+func (s *Server) GetEjectedUntil() time.Time {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.ejectedUntil
+}
@@ -0,0 +1,403 @@
+package balancer
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend server struct
+type Server struct {
+	Address   string
+	IsHealthy bool
+	Mutex     sync.RWMutex
+	ConCount  int //for least connection algo
+	URL       *url.URL
+	Weight    int //relative share of traffic for weighted round robin
+
+	currentWeight int //running weight used by smooth weighted round robin
+
+	latencyEWMA   float64   //peak ewma of response latency in nanoseconds
+	latencyUpdate time.Time //time of the last latency sample
+
+	//outlier detection from live traffic
+	consecutiveErrors int
+	windowStart       time.Time //start of the error rate window
+	windowRequests    int
+	windowErrors      int
+	ejections         int       //recent ejections, grows the ejection time
+	ejectedUntil      time.Time //the server gets no new requests before this time
+
+	circuit circuitState //circuit breaker from live traffic
+
+	//active health check streak, positive for passes in a row and negative for failures
+	healthStreak     int
+	healthChecked    bool      //false until the first active health check
+	lastTransition   time.Time //time of the last health status change
+	transitionReason string
+	certExpiry       time.Time //expiry of the certificate seen by the last tls health check
+	rampStart        time.Time //start of the slow start ramp, zero when the server takes full traffic
+	rampOnHealthy    bool      //the next change to healthy starts the ramp, set once the server was seen down or was added
+
+	disabled      bool      //taken out of rotation by an operator, health checks keep running
+	draining      bool      //the server takes no new requests and leaves the pool once idle
+	drainDeadline time.Time //the server is removed at this time even with requests in flight
+
+	pools []*atomic.Uint64 //generations of the balancers holding the server
+}
+
+// NewServer creates a backend server instance for the address, it starts
+// unhealthy until a health check marks it healthy
+func NewServer(address string) (*Server, error) {
+	serverURL, err := url.Parse(address)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL %s: %v", address, err)
+	}
+
+	return &Server{
+		Address:   address,
+		IsHealthy: false, //will be set by the health chech methods
+		ConCount:  0,
+		URL:       serverURL,
+		Weight:    1,
+	}, nil
+}
+
+// getting current connection count
+func (s *Server) GetConnectionCount() int {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.ConCount
+}
+
+// Increments the connection count
+func (s *Server) IncrementConnectionCount() {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.ConCount++
+}
+
+// Decrements the connection count
+func (s *Server) DecrementConnectionCount() {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if s.ConCount > 0 {
+		s.ConCount--
+	}
+}
+
+// getting the server weight, servers without a weight count as 1
+func (s *Server) GetWeight() int {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+// setting the server weight (thread safe)
+func (s *Server) SetWeight(weight int) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.Weight = weight
+	s.currentWeight = 0
+}
+
+// recording a response latency into the peak ewma, a sample above the
+// average replaces it immediately while lower samples are blended in with the half life
+func (s *Server) RecordLatency(latency, halfLife time.Duration) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.recordLatencyAt(time.Now(), latency, halfLife)
+}
+
+// blending the sample into the stored ewma, caller must hold the mutex
+func (s *Server) recordLatencyAt(now time.Time, latency, halfLife time.Duration) {
+	sample := float64(latency)
+	if s.latencyUpdate.IsZero() || sample > s.latencyEWMA {
+		s.latencyEWMA = sample
+	} else {
+		w := decayWeight(now.Sub(s.latencyUpdate), halfLife)
+		s.latencyEWMA = s.latencyEWMA*w + sample*(1-w)
+	}
+	s.latencyUpdate = now
+}
+
+// getting the latency ewma decayed up to now, zero if nothing was recorded yet
+func (s *Server) GetLatencyEWMA(halfLife time.Duration) time.Duration {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return time.Duration(s.latencyEWMAAt(time.Now(), halfLife))
+}
+
+// decaying the stored ewma towards zero for reads only, so a server that was slow
+// and stopped receiving traffic gets another chance, caller must hold the mutex
+func (s *Server) latencyEWMAAt(now time.Time, halfLife time.Duration) float64 {
+	if s.latencyUpdate.IsZero() {
+		return 0
+	}
+	return s.latencyEWMA * decayWeight(now.Sub(s.latencyUpdate), halfLife)
+}
+
+// weight of the old value after elapsed time for the given half life
+func decayWeight(elapsed, halfLife time.Duration) float64 {
+	if halfLife <= 0 || elapsed <= 0 {
+		return 1
+	}
+	return math.Exp2(-float64(elapsed) / float64(halfLife))
+}
+
+// Returning if the server is healthy (thread safe)
+func (s *Server) IsServerHealthy() bool {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.IsHealthy
+}
+
+// checking if the server can take new requests, it must be healthy, enabled, not ejected
+// or draining and its circuit breaker must let requests through (thread safe)
+func (s *Server) IsAvailable() bool {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.available(time.Now())
+}
+
+// availability check for the algorithms, caller must hold the mutex
+func (s *Server) available(now time.Time) bool {
+	return s.IsHealthy && !s.disabled && !s.draining && !now.Before(s.ejectedUntil) && s.breakerAllows(now)
+}
+
+// time a healthy server that is not available now may become available again,
+// zero if that takes a health change, caller must hold the mutex
+func (s *Server) availableAt(now time.Time) time.Time {
+	if !s.IsHealthy || s.disabled || s.draining {
+		return time.Time{}
+	}
+	at := s.breakerAllowsAt(now)
+	if s.ejectedUntil.After(at) {
+		at = s.ejectedUntil
+	}
+	return at
+}
+
+// bumping the generation of every balancer holding the server after its availability
+// changed, so their lookup tables are rebuilt, caller must hold the mutex
+func (s *Server) changed() {
+	for _, generation := range s.pools {
+		generation.Add(1)
+	}
+}
+
+// tracking a balancer the server was added to (thread safe)
+func (s *Server) join(generation *atomic.Uint64) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for _, g := range s.pools {
+		if g == generation {
+			return
+		}
+	}
+	s.pools = append(s.pools, generation)
+}
+
+// forgetting a balancer the server was removed from (thread safe)
+func (s *Server) leave(generation *atomic.Uint64) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for i, g := range s.pools {
+		if g == generation {
+			s.pools = append(s.pools[:i:i], s.pools[i+1:]...)
+			return
+		}
+	}
+}
+
+// taking the server out of rotation or putting it back (thread safe), reporting whether it changed
+func (s *Server) SetEnabled(enabled bool) bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if s.disabled == !enabled {
+		return false
+	}
+	s.changed()
+	s.disabled = !enabled
+	return true
+}
+
+// checking if the server was taken out of rotation by an operator (thread safe)
+func (s *Server) IsDisabled() bool {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.disabled
+}
+
+// setting the health status (thread safe), reporting whether it changed
+func (s *Server) SetHealthy(healthy bool) bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.setHealthy(healthy, "status set directly")
+}
+
+// changing the health status and recording the transition, caller must hold the mutex
+func (s *Server) setHealthy(healthy bool, reason string) bool {
+	if s.IsHealthy == healthy {
+		return false
+	}
+	s.changed()
+	s.IsHealthy = healthy
+	s.lastTransition = time.Now()
+	s.transitionReason = reason
+	//the first healthy check of a server that was in the pool from the start is not a recovery
+	if healthy && s.rampOnHealthy {
+		s.rampStart = s.lastTransition
+	}
+	s.rampOnHealthy = !healthy
+	return true
+}
+
+// recording an active health check result (thread safe), the status only changes after
+// the threshold of results in a row so a lossy network does not flap it, except for
+// the first check which decides at once, reporting whether the status changed
+func (s *Server) RecordHealthCheck(passed bool, reason string, healthyThreshold, unhealthyThreshold int) bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if passed {
+		s.healthStreak = max(s.healthStreak, 0) + 1
+	} else {
+		s.healthStreak = min(s.healthStreak, 0) - 1
+	}
+	first := !s.healthChecked
+	s.healthChecked = true
+
+	if passed && (first || s.healthStreak >= healthyThreshold) {
+		return s.setHealthy(true, fmt.Sprintf("%s (%d in a row)", reason, s.healthStreak))
+	}
+	if !passed && (first || -s.healthStreak >= unhealthyThreshold) {
+		return s.setHealthy(false, fmt.Sprintf("%s (%d in a row)", reason, -s.healthStreak))
+	}
+	return false
+}
+
+// getting the active health check streak, positive for passes in a row and negative for failures
+func (s *Server) GetHealthStreak() int {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.healthStreak
+}
+
+// recording the expiry of the certificate the server presented to a tls health check
+func (s *Server) SetCertExpiry(expiry time.Time) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.certExpiry = expiry
+}
+
+// getting the expiry of the server certificate, zero if it was never checked over tls
+func (s *Server) GetCertExpiry() time.Time {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.certExpiry
+}
+
+// getting the time and reason of the last health status change, zero if it never changed
+func (s *Server) GetLastTransition() (time.Time, string) {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.lastTransition, s.transitionReason
+}
+
+// returning server info as a map
+func (s *Server) GetServerInfo() map[string]interface{} {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+
+	return map[string]interface{}{
+		"address":                s.Address,
+		"healthy":                s.IsHealthy,
+		"connnections":           s.ConCount,
+		"weight":                 s.Weight,
+		"ejected":                time.Now().Before(s.ejectedUntil),
+		"breaker":                s.breakerState(time.Now()).String(),
+		"health_streak":          s.healthStreak,
+		"last_transition":        s.lastTransition,
+		"last_transition_reason": s.transitionReason,
+		"cert_expiry":            s.certExpiry,
+		"disabled":               s.disabled,
+		"draining":               s.draining,
+	}
+}
+
+// http handler for monitoring active connections
+func (s *Server) ActiveConnectionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		s.Mutex.RLock()
+		defer s.Mutex.RUnlock()
+
+		healthStatus := "unhealthy"
+		if s.IsHealthy {
+			healthStatus = "healthy"
+		}
+
+		response := fmt.Sprintf(`{
+		"address":"%s",
+		"active-connections":"%d",
+		"status":"%s"
+		}`, s.Address, s.ConCount, healthStatus)
+
+		log.Printf("Status check for %s: %d connections, %s", s.Address, s.ConCount, healthStatus)
+		fmt.Fprintln(w, response)
+	}
+}
+
+// returning the string representation of the server
+func (s *Server) String() string {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+
+	status := "unhealthy"
+	if s.IsHealthy {
+		status = "healthy"
+	}
+	return fmt.Sprintf("Server(Address: %s, Status: %s, Connections: %d", s.Address, status, s.ConCount)
+}
+
+// Resetting the server connection count and health status
+func (s *Server) Reset() {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.IsHealthy {
+		s.changed()
+	}
+	s.ConCount = 0
+	s.IsHealthy = false
+	s.rampOnHealthy = true
+	s.healthStreak = 0
+	s.healthChecked = false
+	log.Printf("Server %s has been reset", s.Address)
+}
+
+// Creating a copy of the server for testing
+func (s *Server) Clone() *Server {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+
+	return &Server{
+		Address:   s.Address,
+		IsHealthy: s.IsHealthy,
+		ConCount:  s.ConCount,
+		URL:       s.URL,
+		Weight:    s.Weight,
+	}
+}
@@ -0,0 +1,102 @@
+package balancer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// shapes of the slow start ramp
+const (
+	SlowStartLinear      = "linear"
+	SlowStartExponential = "exponential"
+)
+
+// settings for ramping up traffic to a server that just became healthy
+type SlowStart struct {
+	Window      time.Duration //time to reach full traffic, 0 disables slow start
+	Mode        string        //linear or exponential
+	MinFraction float64       //share of full traffic at the start of the window
+}
+
+// slow start settings used when none are configured, disabled until Window is set
+func DefaultSlowStart() SlowStart {
+	return SlowStart{
+		Mode:        SlowStartLinear,
+		MinFraction: 0.1,
+	}
+}
+
+// checking the slow start settings from the config
+func ValidateSlowStart(ss SlowStart) error {
+	if ss.Window < 0 {
+		return fmt.Errorf("slow start window must not be negative")
+	}
+	if ss.Mode != SlowStartLinear && ss.Mode != SlowStartExponential {
+		return fmt.Errorf("unknown slow start mode %s, expected %s or %s", ss.Mode, SlowStartLinear, SlowStartExponential)
+	}
+	if ss.MinFraction <= 0 || ss.MinFraction > 1 {
+		return fmt.Errorf("slow start min fraction must be above 0 and at most 1")
+	}
+	return nil
+}
+
+// setting the slow start ramp
+func (lb *Balancer) ConfigureSlowStart(ss SlowStart) {
+	lb.Mutex.Lock()
+	defer lb.Mutex.Unlock()
+
+	lb.SlowStart = ss
+}
+
+// getting the slow start ramp
+func (lb *Balancer) GetSlowStart() SlowStart {
+	lb.Mutex.RLock()
+	defer lb.Mutex.RUnlock()
+
+	return lb.SlowStart
+}
+
+// getting the share of full traffic the server gets, below 1 while it ramps up (thread safe)
+func (s *Server) GetSlowStartRamp(ss SlowStart) float64 {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.slowStartRamp(time.Now(), ss)
+}
+
+// share of full traffic the server gets, ramping from the min fraction to 1 over the
+// window after it recovered or joined the pool, caller must hold the mutex
+func (s *Server) slowStartRamp(now time.Time, ss SlowStart) float64 {
+	if ss.Window <= 0 || !s.IsHealthy || s.rampStart.IsZero() {
+		return 1
+	}
+	elapsed := now.Sub(s.rampStart)
+	if elapsed >= ss.Window {
+		return 1
+	}
+
+	progress := float64(elapsed) / float64(ss.Window)
+	minFraction := math.Min(math.Max(ss.MinFraction, 0.01), 1)
+	if ss.Mode == SlowStartExponential {
+		return minFraction * math.Pow(1/minFraction, progress)
+	}
+	return minFraction + (1-minFraction)*progress
+}
+
+// deciding at random whether a ramping server takes the request, a server at
+// full traffic always does (thread safe)
+func (s *Server) admitSlowStart(now time.Time, ss SlowStart) bool {
+	s.Mutex.RLock()
+	ramp := s.slowStartRamp(now, ss)
+	s.Mutex.RUnlock()
+
+	return ramp >= 1 || rand.Float64() < ramp
+}
+
+// connections plus the new request scaled up while the server ramps, lower is less loaded (thread safe)
+func (s *Server) rampedLoad(now time.Time, ss SlowStart) float64 {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return float64(s.ConCount+1) / s.slowStartRamp(now, ss)
+}
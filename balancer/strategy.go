@@ -0,0 +1,128 @@
+package balancer
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Strategy selects the next server from the balancer's pool for a request,
+// the request is nil when selection happens outside of a request, a server it
+// returns that is unhealthy, disabled, draining, ejected or blocked by its breaker
+// is passed over, as is a ramping server for its share of the requests, and the
+// first available server is used when it keeps returning such servers
+type Strategy interface {
+	Next(lb *Balancer, r *http.Request) *Server
+}
+
+// adapter to use an ordinary function as a Strategy
+type StrategyFunc func(lb *Balancer, r *http.Request) *Server
+
+func (f StrategyFunc) Next(lb *Balancer, r *http.Request) *Server {
+	return f(lb, r)
+}
+
+// registry of load balancing algorithms by name
+var (
+	strategies      = make(map[string]Strategy)
+	builtins        = make(map[string]bool) //built in algorithms, they cannot be unregistered
+	strategiesMutex sync.RWMutex
+)
+
+// registering the built in algorithms
+func init() {
+	algorithms := map[string]Strategy{
+		"round-robin": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerRoundRobin()
+		}),
+		"weighted-round-robin": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerWeightedRoundRobin()
+		}),
+		"least-connections": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerLL()
+		}),
+		"p2c": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerP2C()
+		}),
+		"peak-ewma": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerPeakEWMA()
+		}),
+		"least-latency": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerPeakEWMA()
+		}),
+		"consistent-hash": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerConsistentHash(r)
+		}),
+		"maglev": StrategyFunc(func(lb *Balancer, r *http.Request) *Server {
+			return lb.GetNextServerMaglev(r)
+		}),
+	}
+
+	for name, strategy := range algorithms {
+		if err := RegisterStrategy(name, strategy); err != nil {
+			panic(err)
+		}
+		builtins[name] = true
+	}
+}
+
+// registering a load balancing algorithm so it can be selected by name in the config or SetAlgorithm
+func RegisterStrategy(name string, strategy Strategy) error {
+	if name == "" || strategy == nil {
+		return fmt.Errorf("strategy needs a name and an implementation")
+	}
+
+	strategiesMutex.Lock()
+	defer strategiesMutex.Unlock()
+
+	if _, exists := strategies[name]; exists {
+		return fmt.Errorf("strategy %s is already registered", name)
+	}
+	strategies[name] = strategy
+	return nil
+}
+
+// checking if the algorithm is one of the built in ones
+func isBuiltinStrategy(name string) bool {
+	strategiesMutex.RLock()
+	defer strategiesMutex.RUnlock()
+	return builtins[name]
+}
+
+// removing a registered algorithm, balancers already using it fall back to round robin
+func UnregisterStrategy(name string) error {
+	strategiesMutex.Lock()
+	defer strategiesMutex.Unlock()
+
+	if builtins[name] {
+		return fmt.Errorf("strategy %s is built in", name)
+	}
+	if _, exists := strategies[name]; !exists {
+		return fmt.Errorf("strategy %s is not registered", name)
+	}
+	delete(strategies, name)
+	return nil
+}
+
+// looking up a registered algorithm
+func GetStrategy(name string) (Strategy, bool) {
+	strategiesMutex.RLock()
+	defer strategiesMutex.RUnlock()
+
+	strategy, ok := strategies[name]
+	return strategy, ok
+}
+
+// returning the names of all registered algorithms, sorted
+func StrategyNames() []string {
+	strategiesMutex.RLock()
+	defer strategiesMutex.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+)
+
+// levels of the log lines, a line's level comes from the "Debug:", "Warning:"
+// or "Error:" marker its message starts with and lines without one are info
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// parsing a log level name
+func parseLogLevel(name string) (logLevel, error) {
+	switch name {
+	case "debug":
+		return levelDebug, nil
+	case "info":
+		return levelInfo, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "error":
+		return levelError, nil
+	}
+	return levelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+// level of a log line from the marker at the start of its message, the
+// message starts after the date and time the logger flags put in front
+func lineLevel(line []byte, flags int) logLevel {
+	message := line[min(headerLen(flags), len(line)):]
+	switch {
+	case bytes.HasPrefix(message, []byte("Error:")):
+		return levelError
+	case bytes.HasPrefix(message, []byte("Warning:")):
+		return levelWarn
+	case bytes.HasPrefix(message, []byte("Debug:")):
+		return levelDebug
+	}
+	return levelInfo
+}
+
+// length of the date and time the logger writes before the message
+func headerLen(flags int) int {
+	n := 0
+	if flags&log.Ldate != 0 {
+		n += len("2006/01/02 ")
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n += len("15:04:05 ")
+		if flags&log.Lmicroseconds != 0 {
+			n += len(".000000")
+		}
+	}
+	return n
+}
+
+// writer for the standard logger dropping lines below the minimum level, the
+// logger writes each line with a single call and without a file name or prefix
+// in front of the message
+type levelWriter struct {
+	out   io.Writer
+	min   logLevel
+	flags int //flags of the logger writing to it
+}
+
+func (w *levelWriter) Write(line []byte) (int, error) {
+	if lineLevel(line, w.flags) < w.min {
+		return len(line), nil
+	}
+	return w.out.Write(line)
+}
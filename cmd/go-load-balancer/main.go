@@ -0,0 +1,215 @@
+// Command go-load-balancer runs the load balancer configured by config.yaml,
+// run it with -h for the flags and their environment variables
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/config"
+)
+
+// url reaching the listen address from this host
+func localURL(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen + "/"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+// simulating traffic for testing, sending requests to the target url
+func simulateTraffic(ctx context.Context, target string) {
+	log.Println("Load Balancer is running. Simulating traffic...")
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	for i := 0; i < 50; i++ {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping traffic simulation")
+			return
+		default:
+			go func(requestID int) {
+				resp, err := client.Get(target)
+				if err != nil {
+					log.Printf("Warning: request %d failed: %v", requestID, err)
+					return
+				}
+				defer resp.Body.Close()
+
+				body, _ := io.ReadAll(resp.Body)
+				bodyStr := string(body)
+				if len(bodyStr) > 50 {
+					bodyStr = bodyStr[:50] + "..."
+				}
+				log.Printf("Request %d completed: %s", requestID, bodyStr)
+			}(i + 1)
+
+			//Random delay between requests
+			time.Sleep(time.Duration(rand.Intn(1000)) * time.Millisecond)
+		}
+	}
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	log.SetOutput(&levelWriter{out: os.Stderr, min: opts.logLevel, flags: log.Flags()})
+
+	log.Println("Load balancer starting...")
+
+	//loading the config file, the admin listener from the command line replaces the configured one
+	applyOptions := func(cfg *config.Config) error {
+		if opts.adminListen == "" {
+			return nil
+		}
+		return cfg.SetAdminListen(opts.adminListen)
+	}
+	cfg, err := config.Load(opts.configPath)
+	if err == nil {
+		err = applyOptions(cfg)
+	}
+	if err != nil {
+		log.Fatalf("Error: failed to load the config file: %v", err)
+	}
+
+	//creates the loadbalancer and its servers from the config
+	lb, err := cfg.NewBalancer()
+	if err != nil {
+		log.Fatalf("Error: failed to create the load balancer: %v", err)
+	}
+
+	log.Printf("Load Balancer configured with %d servers using %s algorithm", lb.GetServerCount(), cfg.LoadBalancingAlgo)
+
+	//context for graceful shutdown
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	//	Start Health Checks
+	checker, err := cfg.NewChecker()
+	if err != nil {
+		log.Fatalf("Error: failed to create the health checker: %v", err)
+	}
+	go checker.Watch(lb, cfg.HealthCheckInterval(), ctx)
+
+	//reloading the config on SIGHUP, and on file changes when watching is enabled
+	reloader := config.NewReloader(opts.configPath, cfg, lb, checker)
+	reloader.Prepare = applyOptions
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				log.Println("Received SIGHUP, reloading the config")
+				reloader.Reload()
+			}
+		}
+	}()
+	if cfg.Reload.Watch {
+		go reloader.Watch(ctx, time.Duration(cfg.Reload.PollInterval)*time.Second)
+	}
+
+	//wait for initial healthchecks
+	time.Sleep(2 * time.Second)
+
+	//starting HTTP server with the proxy and status handlers
+	lbProxy, err := cfg.NewProxy(lb)
+	if err != nil {
+		log.Fatalf("Error: failed to create the proxy: %v", err)
+	}
+	if opts.logLevel == levelDebug {
+		//every forwarded request is logged, only worth it when debugging
+		lbProxy.DebugLog = log.New(log.Writer(), "Debug: ", log.Flags()|log.Lmsgprefix)
+	}
+	server := &http.Server{
+		Addr:    opts.listen,
+		Handler: lbProxy.Handler(),
+	}
+
+	//starting the loadbalancer on the listen address
+	baseURL := localURL(opts.listen)
+	go func() {
+		log.Printf("Loadbalancer is running on %s", opts.listen)
+		log.Printf("Endpoints: %s (load balanced), %sstatus (status)", baseURL, baseURL)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error: failed to start the server %v", err)
+		}
+	}()
+
+	//starting the admin api on its own listener
+	adminServer, err := cfg.NewAdminServer(lb, reloader)
+	if err != nil {
+		log.Fatalf("Error: failed to create the admin api: %v", err)
+	}
+	if adminServer != nil {
+		go func() {
+			log.Printf("Admin api is running on %s", adminServer.Addr)
+			var err error
+			if adminServer.TLSConfig != nil {
+				err = adminServer.ListenAndServeTLS("", "")
+			} else {
+				err = adminServer.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Error: failed to start the admin api %v", err)
+			}
+		}()
+	}
+
+	//simulating traffic only when asked for, after waiting briefly for the server to run
+	if opts.simulateTraffic {
+		go func() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			simulateTraffic(ctx, baseURL)
+		}()
+	}
+
+	//waiting for signal
+	<-ctx.Done()
+
+	//Graceful shutdown
+	log.Println("Shutting down loadbalancer")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("Error: failed to shutdown gracefully: %v", err)
+	}
+	if adminServer != nil {
+		adminServer.Shutdown(shutdownCtx)
+	}
+	lbProxy.Close()
+	log.Println("Loadbalancer stopped successfully")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+// environment lookup over a map
+func mapEnv(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected options
+	}{
+		{
+			name:     "defaults",
+			expected: options{configPath: "config.yaml", listen: ":8080", logLevel: levelInfo},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				envConfig:          "/etc/lb/config.yaml",
+				envListen:          ":9000",
+				envAdminListen:     "127.0.0.1:9090",
+				envLogLevel:        "warn",
+				envSimulateTraffic: "true",
+			},
+			expected: options{configPath: "/etc/lb/config.yaml", listen: ":9000", adminListen: "127.0.0.1:9090", logLevel: levelWarn, simulateTraffic: true},
+		},
+		{
+			name: "flags win over the environment",
+			args: []string{"-config", "local.yaml", "-listen", ":8000", "-admin-listen", ":9091", "-log-level", "debug", "-simulate-traffic=false"},
+			env: map[string]string{
+				envConfig:          "/etc/lb/config.yaml",
+				envListen:          ":9000",
+				envLogLevel:        "error",
+				envSimulateTraffic: "1",
+			},
+			expected: options{configPath: "local.yaml", listen: ":8000", adminListen: ":9091", logLevel: levelDebug},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseOptions(tt.args, mapEnv(tt.env), io.Discard)
+			if err != nil {
+				t.Fatalf("Failed to parse the options, %v", err)
+			}
+			if opts != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"-log-level", "verbose"}, {"-port", "80"}, {"serve"}} {
+		if _, err := parseOptions(args, mapEnv(nil), io.Discard); err == nil {
+			t.Errorf("Expected an error for the arguments %v", args)
+		}
+	}
+	if _, err := parseOptions(nil, mapEnv(map[string]string{envSimulateTraffic: "sometimes"}), io.Discard); err == nil {
+		t.Errorf("Expected an error for an invalid %s", envSimulateTraffic)
+	}
+	if _, err := parseOptions([]string{"-h"}, mapEnv(nil), io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp for -h, got %v", err)
+	}
+}
+
+func TestLevelWriter(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&levelWriter{out: &out, min: levelWarn, flags: log.LstdFlags}, "", log.LstdFlags)
+
+	logger.Printf("Debug: request forwarded to %s", "http://localhost:8081")
+	logger.Printf("Added server %s", "http://localhost:8083")
+	logger.Printf("Admin added server %s", "http://errors.local/Error:")
+	logger.Printf("Circuit breaker for %s closed -> open: %s", "http://localhost:8084", "Warning: slow")
+	logger.Printf("Warning: certificate of server %s expires soon", "https://localhost:8443")
+	logger.Printf("Error: invalid admin config: %s", "missing token")
+
+	logged := out.String()
+	if strings.Contains(logged, "request forwarded") || strings.Contains(logged, "Added server") ||
+		strings.Contains(logged, "Admin added server") || strings.Contains(logged, "Circuit breaker") {
+		t.Errorf("Expected debug and info lines to be dropped, got %q", logged)
+	}
+	if !strings.Contains(logged, "Warning: certificate") || !strings.Contains(logged, "Error: invalid admin config") {
+		t.Errorf("Expected warning and error lines to be kept, got %q", logged)
+	}
+
+	//the proxy's debug logger puts its marker after the date and time
+	out.Reset()
+	writer := &levelWriter{out: &out, min: levelInfo, flags: log.LstdFlags}
+	log.New(writer, "Debug: ", log.LstdFlags|log.Lmsgprefix).Printf("request forwarded to %s", "http://localhost:8081")
+	if out.Len() != 0 {
+		t.Errorf("Expected the debug logger's lines to be dropped at info, got %q", out.String())
+	}
+	writer.min = levelDebug
+	log.New(writer, "Debug: ", log.LstdFlags|log.Lmsgprefix).Printf("request forwarded to %s", "http://localhost:8081")
+	if !strings.Contains(out.String(), "Debug: request forwarded") {
+		t.Errorf("Expected the debug logger's lines to be kept at debug, got %q", out.String())
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	tests := map[string]string{
+		":8080":          "http://localhost:8080/",
+		"0.0.0.0:8080":   "http://localhost:8080/",
+		"[::]:8080":      "http://localhost:8080/",
+		"127.0.0.1:9000": "http://127.0.0.1:9000/",
+		"lb.local:80":    "http://lb.local:80/",
+	}
+	for listen, expected := range tests {
+		if got := localURL(listen); got != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, listen, got)
+		}
+	}
+}
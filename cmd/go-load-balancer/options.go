@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// environment variables setting the flags
+const (
+	envConfig          = "LB_CONFIG"
+	envListen          = "LB_LISTEN"
+	envAdminListen     = "LB_ADMIN_LISTEN"
+	envLogLevel        = "LB_LOG_LEVEL"
+	envSimulateTraffic = "LB_SIMULATE_TRAFFIC"
+)
+
+// command-line options, each flag can be set by its environment variable and
+// a flag given on the command line wins over the environment
+type options struct {
+	configPath      string
+	listen          string
+	adminListen     string //overrides admin.listen from the config when set
+	logLevel        logLevel
+	simulateTraffic bool
+}
+
+// parsing the options from the arguments and the environment, usage and
+// errors of the flags are written to output
+func parseOptions(args []string, getenv func(string) string, output io.Writer) (options, error) {
+	envOr := func(name, fallback string) string {
+		if value := getenv(name); value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	simulate := false
+	if value := getenv(envSimulateTraffic); value != "" {
+		var err error
+		if simulate, err = strconv.ParseBool(value); err != nil {
+			return options{}, fmt.Errorf("invalid %s %q, expected true or false", envSimulateTraffic, value)
+		}
+	}
+
+	var opts options
+	var level string
+	flags := flag.NewFlagSet("go-load-balancer", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.configPath, "config", envOr(envConfig, "config.yaml"), "path of the yaml config file ($"+envConfig+")")
+	flags.StringVar(&opts.listen, "listen", envOr(envListen, ":8080"), "address the load balancer listens on ($"+envListen+")")
+	flags.StringVar(&opts.adminListen, "admin-listen", getenv(envAdminListen), "address of the admin api, overrides admin.listen in the config ($"+envAdminListen+")")
+	flags.StringVar(&level, "log-level", envOr(envLogLevel, "info"), "debug, info, warn or error ($"+envLogLevel+")")
+	flags.BoolVar(&opts.simulateTraffic, "simulate-traffic", simulate, "send 50 test requests through the load balancer after startup ($"+envSimulateTraffic+")")
+
+	if err := flags.Parse(args); err != nil {
+		return options{}, err
+	}
+	if flags.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+	var err error
+	if opts.logLevel, err = parseLogLevel(level); err != nil {
+		return options{}, err
+	}
+	return opts, nil
+}
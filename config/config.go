@@ -0,0 +1,705 @@
+// Package config loads the yaml configuration of the load balancer and
+// builds a balancer from it.
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/admin"
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+	"github.com/SusheelSathyaraj/go-load-balancer/health"
+	"github.com/SusheelSathyaraj/go-load-balancer/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+type ServerConfig struct {
+	Address     string             `yaml:"address"`
+	Weight      int                `yaml:"weight"`       //only used by weighted-round-robin, defaults to 1
+	HealthCheck *HealthCheckConfig `yaml:"health_check"` //fields set here override the global health check
+}
+
+// active health check request, unset fields keep the default GET /health expecting 200
+type HealthCheckConfig struct {
+	Type           string            `yaml:"type"` //http, tcp, tls or grpc
+	Path           string            `yaml:"path"`
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	Host           string            `yaml:"host"`
+	Timeout        int               `yaml:"timeout"`         //in seconds
+	ExpectedStatus []string          `yaml:"expected_status"` //codes (200), ranges (200-299) or classes (2xx)
+	BodyContains   string            `yaml:"body_contains"`
+	BodyRegex      string            `yaml:"body_regex"`
+
+	Interval   int `yaml:"interval"`    //in seconds, zero uses health_check_interval
+	MaxBackoff int `yaml:"max_backoff"` //in seconds, upper bound for re-probing an unhealthy server
+
+	HealthyThreshold   int `yaml:"healthy_threshold"`   //passes in a row before an unhealthy server is healthy again
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"` //failures in a row before a healthy server is unhealthy
+
+	Service string `yaml:"service"` //grpc checks only
+
+	//tls checks and grpc checks of https servers
+	VerifyCertificate *bool  `yaml:"verify_certificate"`
+	ServerName        string `yaml:"server_name"`
+	CAFile            string `yaml:"ca_file"`             //pem bundle trusted when verifying, empty uses the system pool
+	CertExpiryWarning int    `yaml:"cert_expiry_warning"` //in days
+}
+
+type ConsistentHashConfig struct {
+	Key          string `yaml:"key"`  //ip, header, cookie or path
+	Name         string `yaml:"name"` //header or cookie name
+	VirtualNodes int    `yaml:"virtual_nodes"`
+}
+
+// backend connection settings, timeouts in seconds, zero keeps the default
+type ProxyConfig struct {
+	MaxIdleConns          int  `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int  `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int  `yaml:"max_conns_per_host"`
+	IdleConnTimeout       int  `yaml:"idle_conn_timeout"`
+	DialTimeout           int  `yaml:"dial_timeout"`
+	TLSHandshakeTimeout   int  `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout int  `yaml:"response_header_timeout"`
+	PreserveHost          bool `yaml:"preserve_host"`
+	FlushInterval         int  `yaml:"flush_interval_ms"` //in milliseconds, -1 flushes every write
+}
+
+// forwarding header settings
+type ForwardingConfig struct {
+	TrustedProxies  []string `yaml:"trusted_proxies"`  //CIDRs or IPs whose X-Forwarded-* headers are kept
+	ForwardedHeader bool     `yaml:"forwarded_header"` //also send the RFC 7239 Forwarded header
+	ViaPseudonym    string   `yaml:"via_pseudonym"`    //name of this hop in the Via header
+}
+
+// limits for websocket and other upgraded connections in seconds, zero means no limit
+type WebSocketConfig struct {
+	IdleTimeout int `yaml:"idle_timeout"`
+	MaxDuration int `yaml:"max_duration"`
+}
+
+// retries of failed requests on another backend
+type RetryConfig struct {
+	Attempts     int      `yaml:"attempts"`       //retries after the first attempt, 0 disables retries
+	BufferBodies bool     `yaml:"buffer_bodies"`  //buffer request bodies so they can be retried
+	MaxBodyBytes int64    `yaml:"max_body_bytes"` //larger bodies are never retried
+	BudgetRatio  *float64 `yaml:"budget_ratio"`   //retries earned per request, 0 earns none, -1 turns the budget off, unset uses the default
+}
+
+// ejecting servers that fail live traffic, times in seconds
+type OutlierDetectionConfig struct {
+	ConsecutiveErrors  int     `yaml:"consecutive_errors"` //-1 disables
+	ErrorRate          float64 `yaml:"error_rate"`         //share of failed requests, -1 disables
+	MinRequests        int     `yaml:"min_requests"`
+	Window             int     `yaml:"window"`
+	BaseEjectionTime   int     `yaml:"base_ejection_time"`
+	MaxEjectionTime    int     `yaml:"max_ejection_time"`
+	MaxEjectionPercent *int    `yaml:"max_ejection_percent"` //0 never ejects, unset uses the default
+}
+
+// circuit breaker around each server, times in seconds
+type CircuitBreakerConfig struct {
+	FailureThreshold int     `yaml:"failure_threshold"` //-1 disables
+	ErrorRate        float64 `yaml:"error_rate"`        //share of failed requests, -1 disables
+	MinRequests      int     `yaml:"min_requests"`
+	Window           int     `yaml:"window"`
+	Cooldown         int     `yaml:"cooldown"`
+	HalfOpenRequests int     `yaml:"half_open_requests"`
+}
+
+// traffic ramp for servers that just became healthy
+type SlowStartConfig struct {
+	Window     int     `yaml:"window"`      //in seconds, 0 disables slow start
+	Mode       string  `yaml:"mode"`        //linear or exponential
+	MinPercent float64 `yaml:"min_percent"` //share of full traffic at the start of the window
+}
+
+// admin api listener, empty listen disables it, a token or a client ca is required
+type AdminConfig struct {
+	Listen   string `yaml:"listen"`   //address of the admin listener, e.g. "127.0.0.1:9090"
+	Token    string `yaml:"token"`    //bearer token required on every request
+	TLSCert  string `yaml:"tls_cert"` //serve the api over tls with this certificate
+	TLSKey   string `yaml:"tls_key"`
+	ClientCA string `yaml:"client_ca"` //require client certificates signed by this ca (mTLS)
+}
+
+// reloading the config file while running, SIGHUP always reloads
+type ReloadConfig struct {
+	Watch        bool `yaml:"watch"`         //also reload when the file changes
+	PollInterval int  `yaml:"poll_interval"` //in seconds, how often the watched file is checked
+}
+
+type Config struct {
+	Servers              []ServerConfig         `yaml:"servers"`
+	HealthCheckIntervals int                    `yaml:"health_check_interval"`
+	HealthCheck          HealthCheckConfig      `yaml:"health_check"`
+	LoadBalancingAlgo    string                 `yaml:"load_balancing_algorithm"`
+	ConsistentHash       ConsistentHashConfig   `yaml:"consistent_hash"`
+	EWMAHalfLife         int                    `yaml:"ewma_half_life"`    //in seconds, used by peak-ewma
+	MaglevTableSize      int                    `yaml:"maglev_table_size"` //prime, used by maglev
+	Proxy                ProxyConfig            `yaml:"proxy"`
+	Forwarding           ForwardingConfig       `yaml:"forwarding"`
+	WebSocket            WebSocketConfig        `yaml:"websocket"`
+	Retry                RetryConfig            `yaml:"retry"`
+	OutlierDetection     OutlierDetectionConfig `yaml:"outlier_detection"`
+	CircuitBreaker       CircuitBreakerConfig   `yaml:"circuit_breaker"`
+	SlowStart            SlowStartConfig        `yaml:"slow_start"`
+	DrainTimeout         int                    `yaml:"drain_timeout"` //in seconds, time a draining server gets before it is removed
+	Admin                AdminConfig            `yaml:"admin"`
+	Reload               ReloadConfig           `yaml:"reload"`
+}
+
+// Load reads a yaml config file, applies defaults and validates it
+func Load(file string) (*Config, error) {
+
+	configFile, err := os.Open(file)
+	if err != nil {
+		log.Printf("Error: error reading the config file: %v", err)
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	defer configFile.Close()
+
+	var config Config
+	if err := yaml.NewDecoder(configFile).Decode(&config); err != nil {
+		log.Printf("Error: decoding yaml file: %v", err)
+		return nil, fmt.Errorf("error decoding yaml file: %v", err)
+	}
+
+	//setting defaults
+	if config.HealthCheckIntervals == 0 {
+		config.HealthCheckIntervals = 10
+	}
+	if config.LoadBalancingAlgo == "" {
+		config.LoadBalancingAlgo = "round-robin"
+	}
+	if _, ok := balancer.GetStrategy(config.LoadBalancingAlgo); !ok {
+		log.Printf("Error: unknown load balancing algorithm: %s", config.LoadBalancingAlgo)
+		return nil, fmt.Errorf("unknown load balancing algorithm %s, expected one of %v", config.LoadBalancingAlgo, balancer.StrategyNames())
+	}
+	if config.EWMAHalfLife == 0 {
+		config.EWMAHalfLife = 10
+	}
+	if config.MaglevTableSize == 0 {
+		config.MaglevTableSize = balancer.DefaultMaglevTableSize
+	}
+	if err := balancer.ValidateMaglevTableSize(config.MaglevTableSize); err != nil {
+		log.Printf("Error: invalid maglev config: %v", err)
+		return nil, fmt.Errorf("invalid maglev config: %v", err)
+	}
+	if config.ConsistentHash.Key == "" {
+		config.ConsistentHash.Key = balancer.HashKeyIP
+	}
+	if config.ConsistentHash.VirtualNodes == 0 {
+		config.ConsistentHash.VirtualNodes = balancer.DefaultVirtualNodes
+	}
+	if err := balancer.ValidateHashKey(config.ConsistentHash.Key, config.ConsistentHash.Name); err != nil {
+		log.Printf("Error: invalid consistent hash config: %v", err)
+		return nil, fmt.Errorf("invalid consistent hash config: %v", err)
+	}
+	if err := balancer.ValidateSlowStart(config.SlowStart.SlowStart()); err != nil {
+		log.Printf("Error: invalid slow start config: %v", err)
+		return nil, fmt.Errorf("invalid slow start config: %v", err)
+	}
+	if err := config.Retry.validate(); err != nil {
+		log.Printf("Error: invalid retry config: %v", err)
+		return nil, fmt.Errorf("invalid retry config: %v", err)
+	}
+	if err := config.OutlierDetection.validate(); err != nil {
+		log.Printf("Error: invalid outlier detection config: %v", err)
+		return nil, fmt.Errorf("invalid outlier detection config: %v", err)
+	}
+	if err := config.CircuitBreaker.validate(); err != nil {
+		log.Printf("Error: invalid circuit breaker config: %v", err)
+		return nil, fmt.Errorf("invalid circuit breaker config: %v", err)
+	}
+	if err := config.Admin.validate(); err != nil {
+		log.Printf("Error: invalid admin config: %v", err)
+		return nil, fmt.Errorf("invalid admin config: %v", err)
+	}
+	if config.Reload.PollInterval == 0 {
+		config.Reload.PollInterval = 5
+	}
+	if config.Reload.PollInterval < 0 {
+		log.Printf("Error: invalid reload config: poll interval must be positive")
+		return nil, fmt.Errorf("invalid reload config: poll interval must be positive")
+	}
+	if config.Forwarding.ViaPseudonym == "" {
+		config.Forwarding.ViaPseudonym = proxy.DefaultViaPseudonym
+	}
+	if _, err := proxy.ParseTrustedProxies(config.Forwarding.TrustedProxies); err != nil {
+		log.Printf("Error: invalid forwarding config: %v", err)
+		return nil, fmt.Errorf("invalid forwarding config: %v", err)
+	}
+	for i := range config.Servers {
+		if config.Servers[i].Weight <= 0 {
+			config.Servers[i].Weight = 1
+		}
+	}
+	if _, err := config.NewChecker(); err != nil {
+		log.Printf("Error: invalid health check config: %v", err)
+		return nil, fmt.Errorf("invalid health check config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// health check interval as a duration
+func (c *Config) HealthCheckInterval() time.Duration {
+	return time.Duration(c.HealthCheckIntervals) * time.Second
+}
+
+// NewChecker creates the active health checker, servers with their own
+// health check settings get the global settings merged under them
+func (c *Config) NewChecker() (*health.Checker, error) {
+	check, err := c.HealthCheck.CheckConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	checker := health.NewChecker(check)
+	for _, srv := range c.Servers {
+		if srv.HealthCheck == nil {
+			continue
+		}
+		override, err := c.HealthCheck.merge(*srv.HealthCheck).CheckConfig()
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %v", srv.Address, err)
+		}
+		checker.Servers[srv.Address] = override
+	}
+	return checker, nil
+}
+
+// NewBalancer creates the servers and a balancer configured from the config,
+// servers start unhealthy until the first health check
+func (c *Config) NewBalancer() (*balancer.Balancer, error) {
+	servers := make([]*balancer.Server, len(c.Servers))
+	for i, srv := range c.Servers {
+		server, err := balancer.NewServer(srv.Address)
+		if err != nil {
+			return nil, err
+		}
+		server.Weight = srv.Weight
+		servers[i] = server
+	}
+
+	lb := balancer.NewLoadBalancer(servers, c.LoadBalancingAlgo)
+	lb.EWMAHalfLife = time.Duration(c.EWMAHalfLife) * time.Second
+	if err := lb.ConfigureConsistentHash(c.ConsistentHash.Key, c.ConsistentHash.Name, c.ConsistentHash.VirtualNodes); err != nil {
+		return nil, fmt.Errorf("invalid consistent hash config: %v", err)
+	}
+	if err := lb.ConfigureMaglev(c.MaglevTableSize); err != nil {
+		return nil, fmt.Errorf("invalid maglev config: %v", err)
+	}
+	lb.ConfigureOutlierDetection(c.OutlierDetection.OutlierDetection())
+	lb.ConfigureCircuitBreaker(c.CircuitBreaker.CircuitBreaker())
+	lb.ConfigureSlowStart(c.SlowStart.SlowStart())
+	if c.DrainTimeout > 0 {
+		lb.ConfigureDrainTimeout(time.Duration(c.DrainTimeout) * time.Second)
+	}
+	return lb, nil
+}
+
+// NewProxy creates the http proxy for the balancer with the configured transport and forwarding settings
+func (c *Config) NewProxy(lb *balancer.Balancer) (*proxy.Proxy, error) {
+	trustedProxies, err := proxy.ParseTrustedProxies(c.Forwarding.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forwarding config: %v", err)
+	}
+
+	p := proxy.NewWithTransport(lb, c.Proxy.TransportConfig())
+	p.Forwarding = proxy.ForwardingConfig{
+		TrustedProxies: trustedProxies,
+		EmitForwarded:  c.Forwarding.ForwardedHeader,
+		ViaPseudonym:   c.Forwarding.ViaPseudonym,
+	}
+	p.Tunnel = proxy.TunnelConfig{
+		IdleTimeout: time.Duration(c.WebSocket.IdleTimeout) * time.Second,
+		MaxDuration: time.Duration(c.WebSocket.MaxDuration) * time.Second,
+	}
+	p.Retry = c.Retry.RetryPolicy()
+	return p, nil
+}
+
+// transport settings with defaults for the fields that are not set
+func (pc ProxyConfig) TransportConfig() proxy.TransportConfig {
+	cfg := proxy.DefaultTransportConfig()
+
+	if pc.MaxIdleConns > 0 {
+		cfg.MaxIdleConns = pc.MaxIdleConns
+	}
+	if pc.MaxIdleConnsPerHost > 0 {
+		cfg.MaxIdleConnsPerHost = pc.MaxIdleConnsPerHost
+	}
+	if pc.MaxConnsPerHost > 0 {
+		cfg.MaxConnsPerHost = pc.MaxConnsPerHost
+	}
+	if pc.IdleConnTimeout > 0 {
+		cfg.IdleConnTimeout = time.Duration(pc.IdleConnTimeout) * time.Second
+	}
+	if pc.DialTimeout > 0 {
+		cfg.DialTimeout = time.Duration(pc.DialTimeout) * time.Second
+	}
+	if pc.TLSHandshakeTimeout > 0 {
+		cfg.TLSHandshakeTimeout = time.Duration(pc.TLSHandshakeTimeout) * time.Second
+	}
+	if pc.ResponseHeaderTimeout > 0 {
+		cfg.ResponseHeaderTimeout = time.Duration(pc.ResponseHeaderTimeout) * time.Second
+	}
+	cfg.PreserveHost = pc.PreserveHost
+	cfg.FlushInterval = time.Duration(pc.FlushInterval) * time.Millisecond
+
+	return cfg
+}
+
+// retry settings with defaults for the fields that are not set
+func (rc RetryConfig) RetryPolicy() proxy.RetryPolicy {
+	policy := proxy.DefaultRetryPolicy()
+
+	if rc.Attempts > 0 {
+		policy.Attempts = rc.Attempts
+	}
+	policy.BufferBodies = rc.BufferBodies
+	if rc.MaxBodyBytes > 0 {
+		policy.MaxBodyBytes = rc.MaxBodyBytes
+	}
+	if rc.BudgetRatio != nil {
+		policy.BudgetRatio = *rc.BudgetRatio
+	}
+
+	return policy
+}
+
+// checking the retry settings are in range
+func (rc RetryConfig) validate() error {
+	if rc.Attempts < 0 {
+		return fmt.Errorf("attempts must not be negative")
+	}
+	if rc.MaxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must not be negative")
+	}
+	if rc.BudgetRatio != nil && *rc.BudgetRatio != -1 && (*rc.BudgetRatio < 0 || *rc.BudgetRatio > 1) {
+		return fmt.Errorf("budget_ratio must be between 0 and 1 or -1 to disable it, got %v", *rc.BudgetRatio)
+	}
+	return nil
+}
+
+// checking a share of failed requests, -1 disables it
+func validateErrorRate(rate float64) error {
+	if rate != -1 && (rate < 0 || rate > 1) {
+		return fmt.Errorf("error_rate must be between 0 and 1 or -1 to disable it, got %v", rate)
+	}
+	return nil
+}
+
+// checking the outlier detection settings are in range
+func (oc OutlierDetectionConfig) validate() error {
+	if oc.ConsecutiveErrors < -1 {
+		return fmt.Errorf("consecutive_errors must not be negative, or -1 to disable it")
+	}
+	if err := validateErrorRate(oc.ErrorRate); err != nil {
+		return err
+	}
+	if oc.MinRequests < 0 || oc.Window < 0 || oc.BaseEjectionTime < 0 || oc.MaxEjectionTime < 0 {
+		return fmt.Errorf("min_requests, window and ejection times must not be negative")
+	}
+	if oc.MaxEjectionPercent != nil && (*oc.MaxEjectionPercent < 0 || *oc.MaxEjectionPercent > 100) {
+		return fmt.Errorf("max_ejection_percent must be between 0 and 100, got %d", *oc.MaxEjectionPercent)
+	}
+	return nil
+}
+
+// outlier detection settings with defaults for the fields that are not set
+func (oc OutlierDetectionConfig) OutlierDetection() balancer.OutlierDetection {
+	od := balancer.DefaultOutlierDetection()
+
+	if oc.ConsecutiveErrors != 0 {
+		od.ConsecutiveErrors = max(oc.ConsecutiveErrors, 0)
+	}
+	if oc.ErrorRate != 0 {
+		od.ErrorRate = max(oc.ErrorRate, 0)
+	}
+	if oc.MinRequests > 0 {
+		od.MinRequests = oc.MinRequests
+	}
+	if oc.Window > 0 {
+		od.Window = time.Duration(oc.Window) * time.Second
+	}
+	if oc.BaseEjectionTime > 0 {
+		od.BaseEjectionTime = time.Duration(oc.BaseEjectionTime) * time.Second
+	}
+	if oc.MaxEjectionTime > 0 {
+		od.MaxEjectionTime = time.Duration(oc.MaxEjectionTime) * time.Second
+	}
+	if oc.MaxEjectionPercent != nil {
+		od.MaxEjectionPercent = *oc.MaxEjectionPercent
+	}
+
+	return od
+}
+
+// circuit breaker settings with defaults for the fields that are not set
+func (cc CircuitBreakerConfig) CircuitBreaker() balancer.CircuitBreaker {
+	cb := balancer.DefaultCircuitBreaker()
+
+	if cc.FailureThreshold != 0 {
+		cb.FailureThreshold = max(cc.FailureThreshold, 0)
+	}
+	if cc.ErrorRate != 0 {
+		cb.ErrorRate = max(cc.ErrorRate, 0)
+	}
+	if cc.MinRequests > 0 {
+		cb.MinRequests = cc.MinRequests
+	}
+	if cc.Window > 0 {
+		cb.Window = time.Duration(cc.Window) * time.Second
+	}
+	if cc.Cooldown > 0 {
+		cb.Cooldown = time.Duration(cc.Cooldown) * time.Second
+	}
+	if cc.HalfOpenRequests > 0 {
+		cb.HalfOpenRequests = cc.HalfOpenRequests
+	}
+
+	return cb
+}
+
+// checking the circuit breaker settings are in range
+func (cc CircuitBreakerConfig) validate() error {
+	if cc.FailureThreshold < -1 {
+		return fmt.Errorf("failure_threshold must not be negative, or -1 to disable it")
+	}
+	if err := validateErrorRate(cc.ErrorRate); err != nil {
+		return err
+	}
+	if cc.MinRequests < 0 || cc.Window < 0 || cc.Cooldown < 0 || cc.HalfOpenRequests < 0 {
+		return fmt.Errorf("min_requests, window, cooldown and half_open_requests must not be negative")
+	}
+	return nil
+}
+
+// checking the admin api is protected when it is enabled
+func (ac AdminConfig) validate() error {
+	if ac.Listen == "" {
+		return nil
+	}
+	if (ac.TLSCert == "") != (ac.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	if ac.ClientCA != "" && ac.TLSCert == "" {
+		return fmt.Errorf("client_ca requires tls_cert and tls_key")
+	}
+	if ac.Token == "" && ac.ClientCA == "" {
+		return fmt.Errorf("the admin api needs a token or a client_ca")
+	}
+	return nil
+}
+
+// SetAdminListen replaces the address of the admin listener, e.g. from the
+// command line, the admin api still needs a token or a client ca
+func (c *Config) SetAdminListen(listen string) error {
+	ac := c.Admin
+	ac.Listen = listen
+	if err := ac.validate(); err != nil {
+		return fmt.Errorf("invalid admin config: %v", err)
+	}
+	c.Admin = ac
+	return nil
+}
+
+// NewAdminServer creates the admin api server for the balancer, nil when no
+// admin listener is configured, it serves tls when a certificate is set and
+// the reload routes when a reloader is given
+func (c *Config) NewAdminServer(lb *balancer.Balancer, reloader admin.Reloader) (*http.Server, error) {
+	if c.Admin.Listen == "" {
+		return nil, nil
+	}
+
+	api := admin.New(lb, c.Admin.Token)
+	api.Reloader = reloader
+	server := &http.Server{
+		Addr:    c.Admin.Listen,
+		Handler: api.Handler(),
+	}
+	if c.Admin.TLSCert != "" {
+		tlsConfig, err := admin.TLSConfig(c.Admin.TLSCert, c.Admin.TLSKey, c.Admin.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
+	return server, nil
+}
+
+// slow start settings with defaults for the fields that are not set
+func (sc SlowStartConfig) SlowStart() balancer.SlowStart {
+	ss := balancer.DefaultSlowStart()
+
+	ss.Window = time.Duration(sc.Window) * time.Second
+	if sc.Mode != "" {
+		ss.Mode = sc.Mode
+	}
+	if sc.MinPercent != 0 {
+		ss.MinFraction = sc.MinPercent / 100
+	}
+
+	return ss
+}
+
+// combining the settings with an override, fields set in the override win
+// and headers are merged by name
+func (hc HealthCheckConfig) merge(override HealthCheckConfig) HealthCheckConfig {
+	merged := hc
+
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.Path != "" {
+		merged.Path = override.Path
+	}
+	if override.Method != "" {
+		merged.Method = override.Method
+	}
+	if len(override.Headers) > 0 {
+		merged.Headers = make(map[string]string)
+		for name, value := range hc.Headers {
+			merged.Headers[name] = value
+		}
+		for name, value := range override.Headers {
+			merged.Headers[name] = value
+		}
+	}
+	if override.Host != "" {
+		merged.Host = override.Host
+	}
+	if override.Timeout > 0 {
+		merged.Timeout = override.Timeout
+	}
+	if len(override.ExpectedStatus) > 0 {
+		merged.ExpectedStatus = override.ExpectedStatus
+	}
+	if override.BodyContains != "" {
+		merged.BodyContains = override.BodyContains
+	}
+	if override.BodyRegex != "" {
+		merged.BodyRegex = override.BodyRegex
+	}
+	if override.Interval > 0 {
+		merged.Interval = override.Interval
+	}
+	if override.MaxBackoff > 0 {
+		merged.MaxBackoff = override.MaxBackoff
+	}
+	if override.HealthyThreshold > 0 {
+		merged.HealthyThreshold = override.HealthyThreshold
+	}
+	if override.UnhealthyThreshold > 0 {
+		merged.UnhealthyThreshold = override.UnhealthyThreshold
+	}
+	if override.Service != "" {
+		merged.Service = override.Service
+	}
+	if override.VerifyCertificate != nil {
+		merged.VerifyCertificate = override.VerifyCertificate
+	}
+	if override.ServerName != "" {
+		merged.ServerName = override.ServerName
+	}
+	if override.CAFile != "" {
+		merged.CAFile = override.CAFile
+	}
+	if override.CertExpiryWarning > 0 {
+		merged.CertExpiryWarning = override.CertExpiryWarning
+	}
+
+	return merged
+}
+
+// health check settings with defaults for the fields that are not set
+func (hc HealthCheckConfig) CheckConfig() (health.CheckConfig, error) {
+	check := health.DefaultCheckConfig()
+
+	switch strings.ToLower(hc.Type) {
+	case "", health.CheckHTTP:
+	case health.CheckTCP, health.CheckTLS, health.CheckGRPC:
+		check.Type = strings.ToLower(hc.Type)
+	default:
+		return check, fmt.Errorf("unknown health check type %s, expected http, tcp, tls or grpc", hc.Type)
+	}
+	if hc.Path != "" {
+		if !strings.HasPrefix(hc.Path, "/") {
+			return check, fmt.Errorf("health check path %s must start with /", hc.Path)
+		}
+		check.Path = hc.Path
+	}
+	if hc.Method != "" {
+		check.Method = strings.ToUpper(hc.Method)
+	}
+	if len(hc.Headers) > 0 {
+		check.Headers = make(http.Header)
+		for name, value := range hc.Headers {
+			check.Headers.Set(name, value)
+		}
+	}
+	check.Host = hc.Host
+	if hc.Timeout > 0 {
+		check.Timeout = time.Duration(hc.Timeout) * time.Second
+	}
+	if len(hc.ExpectedStatus) > 0 {
+		ranges, err := health.ParseStatusRanges(hc.ExpectedStatus)
+		if err != nil {
+			return check, err
+		}
+		check.ExpectedStatuses = ranges
+	}
+	check.BodyContains = hc.BodyContains
+	if hc.BodyRegex != "" {
+		re, err := regexp.Compile(hc.BodyRegex)
+		if err != nil {
+			return check, fmt.Errorf("invalid body regex: %v", err)
+		}
+		check.BodyRegex = re
+	}
+	if hc.Interval < 0 || hc.MaxBackoff < 0 {
+		return check, fmt.Errorf("health check interval and max backoff must not be negative")
+	}
+	check.Interval = time.Duration(hc.Interval) * time.Second
+	if hc.MaxBackoff > 0 {
+		check.MaxBackoff = time.Duration(hc.MaxBackoff) * time.Second
+	}
+	if hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+		return check, fmt.Errorf("health check thresholds must not be negative")
+	}
+	if hc.HealthyThreshold > 0 {
+		check.HealthyThreshold = hc.HealthyThreshold
+	}
+	if hc.UnhealthyThreshold > 0 {
+		check.UnhealthyThreshold = hc.UnhealthyThreshold
+	}
+	check.Service = hc.Service
+	if hc.VerifyCertificate != nil {
+		check.VerifyCertificate = *hc.VerifyCertificate
+	}
+	check.ServerName = hc.ServerName
+	if hc.CAFile != "" {
+		pem, err := os.ReadFile(hc.CAFile)
+		if err != nil {
+			return check, fmt.Errorf("error reading health check ca file: %v", err)
+		}
+		check.RootCAs = x509.NewCertPool()
+		if !check.RootCAs.AppendCertsFromPEM(pem) {
+			return check, fmt.Errorf("no certificates found in health check ca file %s", hc.CAFile)
+		}
+	}
+	if hc.CertExpiryWarning > 0 {
+		check.CertExpiryWarning = time.Duration(hc.CertExpiryWarning) * 24 * time.Hour
+	}
+
+	return check, nil
+}
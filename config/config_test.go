@@ -0,0 +1,527 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+	"github.com/SusheelSathyaraj/go-load-balancer/proxy"
+)
+
+// writes the config to a temporary file and loads it
+func loadTestConfig(t *testing.T, content string) (*Config, error) {
+	tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config, %v", err)
+	}
+	return Load(tmpFile)
+}
+
+func TestHealthCheckOverrides(t *testing.T) {
+	config, err := loadTestConfig(t, `
+servers:
+  - address: "http://localhost:8081"
+  - address: "http://localhost:8082"
+    health_check:
+      path: "/healthz"
+      headers:
+        X-Service: "orders"
+      expected_status: ["204"]
+      unhealthy_threshold: 5
+  - address: "https://localhost:8443"
+    health_check:
+      type: "tls"
+      verify_certificate: false
+      cert_expiry_warning: 30
+  - address: "http://localhost:9090"
+    health_check:
+      type: "grpc"
+      service: "orders"
+      interval: 30
+health_check:
+  path: "/ready"
+  healthy_threshold: 4
+  verify_certificate: true
+  host: "svc.internal"
+  timeout: 2
+  headers:
+    X-Check: "1"
+  expected_status: ["2xx"]
+`)
+	if err != nil {
+		t.Fatalf("Failed to load the config file, %v", err)
+	}
+
+	checker, err := config.NewChecker()
+	if err != nil {
+		t.Fatalf("Failed to create the checker, %v", err)
+	}
+
+	global := checker.Check
+	if global.Path != "/ready" || global.Host != "svc.internal" || global.Timeout != 2*time.Second || global.Method != http.MethodGet {
+		t.Errorf("Expected the global settings over the defaults, got %+v", global)
+	}
+	if _, ok := checker.Servers["http://localhost:8081"]; ok {
+		t.Errorf("Expected no override for a server without health check settings")
+	}
+
+	override := checker.Servers["http://localhost:8082"]
+	if override.Path != "/healthz" {
+		t.Errorf("Expected the server path to take precedence, got %s", override.Path)
+	}
+	if override.Host != "svc.internal" || override.Timeout != 2*time.Second {
+		t.Errorf("Expected unset server fields to keep the global settings, got %+v", override)
+	}
+	if override.Headers.Get("X-Check") != "1" || override.Headers.Get("X-Service") != "orders" {
+		t.Errorf("Expected headers to be merged, got %v", override.Headers)
+	}
+	if len(override.ExpectedStatuses) != 1 || override.ExpectedStatuses[0].Min != 204 || override.ExpectedStatuses[0].Max != 204 {
+		t.Errorf("Expected the server status to replace the global one, got %v", override.ExpectedStatuses)
+	}
+	if global.Interval != 0 || global.MaxBackoff != time.Minute {
+		t.Errorf("Expected the global check to use the health check interval and a 1m max backoff, got %v and %v", global.Interval, global.MaxBackoff)
+	}
+	if global.HealthyThreshold != 4 || global.UnhealthyThreshold != 3 {
+		t.Errorf("Expected thresholds 4 and 3, got %d and %d", global.HealthyThreshold, global.UnhealthyThreshold)
+	}
+	if override.HealthyThreshold != 4 || override.UnhealthyThreshold != 5 {
+		t.Errorf("Expected server thresholds 4 and 5, got %d and %d", override.HealthyThreshold, override.UnhealthyThreshold)
+	}
+
+	tlsCheck := checker.Servers["https://localhost:8443"]
+	if tlsCheck.Type != "tls" || tlsCheck.VerifyCertificate || tlsCheck.CertExpiryWarning != 30*24*time.Hour {
+		t.Errorf("Expected a tls check without verification warning 30 days ahead, got %+v", tlsCheck)
+	}
+	if grpcCheck := checker.Servers["http://localhost:9090"]; grpcCheck.Type != "grpc" || grpcCheck.Service != "orders" || grpcCheck.Interval != 30*time.Second {
+		t.Errorf("Expected a grpc check of the orders service, got %+v", grpcCheck)
+	}
+	if override.Type != "" || !override.VerifyCertificate {
+		t.Errorf("Expected the http check to keep the global verification, got %+v", override)
+	}
+}
+
+func TestInvalidHealthCheckConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Invalid status", "health_check:\n  expected_status: [\"abc\"]\n"},
+		{"Invalid regex", "health_check:\n  body_regex: \"(\"\n"},
+		{"Relative path", "health_check:\n  path: \"healthz\"\n"},
+		{"Negative interval", "health_check:\n  interval: -5\n"},
+		{"Negative threshold", "health_check:\n  unhealthy_threshold: -1\n"},
+		{"Unknown type", "health_check:\n  type: \"udp\"\n"},
+		{"Missing ca file", "health_check:\n  type: \"tls\"\n  ca_file: \"missing.pem\"\n"},
+		{"Invalid server override", "servers:\n  - address: \"http://localhost:8081\"\n    health_check:\n      expected_status: [\"9xx\"]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadTestConfig(t, tt.content); err == nil {
+				t.Errorf("Expected the invalid health check to be rejected")
+			}
+		})
+	}
+}
+
+func TestRetryBudgetConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected float64
+	}{
+		{"Default", "retry:\n  attempts: 2\n", proxy.DefaultRetryPolicy().BudgetRatio},
+		{"Set", "retry:\n  budget_ratio: 0.5\n", 0.5},
+		{"Reserve only", "retry:\n  budget_ratio: 0\n", 0},
+		{"Off", "retry:\n  budget_ratio: -1\n", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadTestConfig(t, tt.content)
+			if err != nil {
+				t.Fatalf("Failed to load the config file, %v", err)
+			}
+			if ratio := config.Retry.RetryPolicy().BudgetRatio; ratio != tt.expected {
+				t.Errorf("Expected budget ratio %v, got %v", tt.expected, ratio)
+			}
+		})
+	}
+}
+
+func TestMaxEjectionPercentConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected int
+	}{
+		{"Default", "outlier_detection:\n  consecutive_errors: 3\n", balancer.DefaultOutlierDetection().MaxEjectionPercent},
+		{"Set", "outlier_detection:\n  max_ejection_percent: 30\n", 30},
+		{"Never eject", "outlier_detection:\n  max_ejection_percent: 0\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadTestConfig(t, tt.content)
+			if err != nil {
+				t.Fatalf("Failed to load the config file, %v", err)
+			}
+			if percent := config.OutlierDetection.OutlierDetection().MaxEjectionPercent; percent != tt.expected {
+				t.Errorf("Expected max ejection percent %d, got %d", tt.expected, percent)
+			}
+		})
+	}
+}
+
+func TestInvalidRangesConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Negative retry attempts", "retry:\n  attempts: -1\n"},
+		{"Negative max body bytes", "retry:\n  max_body_bytes: -1\n"},
+		{"Negative budget ratio", "retry:\n  budget_ratio: -0.1\n"},
+		{"Budget ratio below -1", "retry:\n  budget_ratio: -2\n"},
+		{"Budget ratio above 1", "retry:\n  budget_ratio: 1.5\n"},
+		{"Outlier error rate above 1", "outlier_detection:\n  error_rate: 50\n"},
+		{"Negative outlier error rate", "outlier_detection:\n  error_rate: -0.5\n"},
+		{"Outlier consecutive errors", "outlier_detection:\n  consecutive_errors: -2\n"},
+		{"Negative ejection time", "outlier_detection:\n  base_ejection_time: -30\n"},
+		{"Ejection percent above 100", "outlier_detection:\n  max_ejection_percent: 150\n"},
+		{"Breaker error rate above 1", "circuit_breaker:\n  error_rate: 1.2\n"},
+		{"Breaker failure threshold", "circuit_breaker:\n  failure_threshold: -5\n"},
+		{"Negative cooldown", "circuit_breaker:\n  cooldown: -1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadTestConfig(t, tt.content); err == nil {
+				t.Errorf("Expected the out of range setting to be rejected")
+			}
+		})
+	}
+
+	//-1 disables the error rates
+	if _, err := loadTestConfig(t, "outlier_detection:\n  error_rate: -1\ncircuit_breaker:\n  error_rate: -1\n"); err != nil {
+		t.Errorf("Expected -1 to disable the error rates, got %v", err)
+	}
+}
+
+// test configuration loading
+func TestSlowStartConfig(t *testing.T) {
+	config, err := loadTestConfig(t, `
+servers:
+  - address: "http://localhost:8081"
+slow_start:
+  window: 60
+  mode: "exponential"
+  min_percent: 5
+`)
+	if err != nil {
+		t.Fatalf("Failed to load the config file, %v", err)
+	}
+	lb, err := config.NewBalancer()
+	if err != nil {
+		t.Fatalf("Failed to create the balancer, %v", err)
+	}
+
+	ss := lb.GetSlowStart()
+	if ss.Window != time.Minute || ss.Mode != "exponential" || ss.MinFraction != 0.05 {
+		t.Errorf("Expected a 1m exponential ramp from 5%%, got %+v", ss)
+	}
+
+	for _, content := range []string{"slow_start:\n  mode: \"step\"\n", "slow_start:\n  min_percent: 150\n", "slow_start:\n  window: -1\n"} {
+		if _, err := loadTestConfig(t, content); err == nil {
+			t.Errorf("Expected the invalid slow start config to be rejected: %q", content)
+		}
+	}
+}
+
+func TestDrainTimeoutConfig(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected time.Duration
+	}{
+		{"servers:\n  - address: \"http://localhost:8081\"\n", 30 * time.Second},
+		{"servers:\n  - address: \"http://localhost:8081\"\ndrain_timeout: 5\n", 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		config, err := loadTestConfig(t, tt.content)
+		if err != nil {
+			t.Fatalf("Failed to load the config file, %v", err)
+		}
+		lb, err := config.NewBalancer()
+		if err != nil {
+			t.Fatalf("Failed to create the balancer, %v", err)
+		}
+		if lb.DrainTimeout != tt.expected {
+			t.Errorf("Expected drain timeout %v, got %v", tt.expected, lb.DrainTimeout)
+		}
+	}
+}
+
+func TestAdminConfig(t *testing.T) {
+	config, err := loadTestConfig(t, "admin:\n  listen: \"127.0.0.1:9090\"\n  token: \"secret\"\n")
+	if err != nil {
+		t.Fatalf("Failed to load the config file, %v", err)
+	}
+	lb, _ := config.NewBalancer()
+	server, err := config.NewAdminServer(lb, nil)
+	if err != nil || server == nil {
+		t.Fatalf("Expected an admin server, got %v, %v", server, err)
+	}
+	if server.Addr != "127.0.0.1:9090" || server.TLSConfig != nil {
+		t.Errorf("Expected a plain admin listener on 127.0.0.1:9090, got %s", server.Addr)
+	}
+
+	//the admin api is off unless a listener is configured
+	config, _ = loadTestConfig(t, "load_balancing_algorithm: \"round-robin\"\n")
+	if server, _ := config.NewAdminServer(lb, nil); server != nil {
+		t.Errorf("Expected no admin server without a listener")
+	}
+
+	for _, content := range []string{
+		"admin:\n  listen: \":9090\"\n",
+		"admin:\n  listen: \":9090\"\n  client_ca: \"ca.pem\"\n",
+		"admin:\n  listen: \":9090\"\n  token: \"secret\"\n  tls_cert: \"cert.pem\"\n",
+	} {
+		if _, err := loadTestConfig(t, content); err == nil {
+			t.Errorf("Expected the unprotected or incomplete admin config to be rejected: %q", content)
+		}
+	}
+
+	//a listener set from the command line is checked like the configured one
+	config, _ = loadTestConfig(t, "admin:\n  token: \"secret\"\n")
+	if err := config.SetAdminListen(":9091"); err != nil || config.Admin.Listen != ":9091" {
+		t.Errorf("Expected the admin listener :9091, got %q, %v", config.Admin.Listen, err)
+	}
+	config, _ = loadTestConfig(t, "load_balancing_algorithm: \"round-robin\"\n")
+	if err := config.SetAdminListen(":9091"); err == nil || config.Admin.Listen != "" {
+		t.Errorf("Expected an admin listener without a token to be rejected")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test config, %v", err)
+		}
+	}
+	write(`
+servers:
+  - address: "http://localhost:8081"
+  - address: "http://localhost:8082"
+health_check_interval: 10
+load_balancing_algorithm: "round-robin"
+`)
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load the config file, %v", err)
+	}
+	lb, _ := config.NewBalancer()
+	checker, _ := config.NewChecker()
+	kept := lb.GetServer("http://localhost:8081")
+	removed := lb.GetServer("http://localhost:8082")
+	removed.IncrementConnectionCount()
+
+	reloader := NewReloader(path, config, lb, checker)
+	if status := reloader.Status(); status.LastStatus != "none" || status.Reloads != 0 {
+		t.Errorf("Expected no reload yet, got %+v", status)
+	}
+
+	write(`
+servers:
+  - address: "http://localhost:8081"
+    weight: 3
+  - address: "http://localhost:8083"
+health_check_interval: 5
+health_check:
+  path: "/ready"
+load_balancing_algorithm: "least-connections"
+`)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Expected the reload to succeed, %v", err)
+	}
+
+	if lb.GetServer("http://localhost:8081") != kept || kept.GetWeight() != 3 {
+		t.Errorf("Expected the kept server to stay in the pool with weight 3, got %d", kept.GetWeight())
+	}
+	if lb.GetServer("http://localhost:8083") == nil {
+		t.Errorf("Expected the new server to be added")
+	}
+	//the removed server drains its request in flight before it leaves the pool
+	if !removed.IsDraining() || lb.GetServer("http://localhost:8082") != removed {
+		t.Errorf("Expected the removed server to drain")
+	}
+	removed.DecrementConnectionCount()
+	deadline := time.Now().Add(2 * time.Second)
+	for lb.GetServer("http://localhost:8082") != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the drained server to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if lb.GetAlgorithm() != "least-connections" {
+		t.Errorf("Expected the algorithm least-connections, got %s", lb.GetAlgorithm())
+	}
+	if checker.Check.Path != "/ready" {
+		t.Errorf("Expected the health check path /ready, got %s", checker.Check.Path)
+	}
+	if status := reloader.Status(); status.LastStatus != "ok" || status.Reloads != 1 || status.LastReload == nil {
+		t.Errorf("Expected one successful reload, got %+v", status)
+	}
+
+	//an invalid config is rejected and the running one kept
+	write(`
+servers:
+  - address: "http://localhost:8084"
+load_balancing_algorithm: "random"
+`)
+	if err := reloader.Reload(); err == nil {
+		t.Fatalf("Expected the invalid config to be rejected")
+	}
+	if lb.GetAlgorithm() != "least-connections" || lb.GetServerCount() != 2 || lb.GetServer("http://localhost:8084") != nil {
+		t.Errorf("Expected the running config to be kept after a rejected reload")
+	}
+	if status := reloader.Status(); status.LastStatus != "failed" || status.Failures != 1 || status.Reloads != 1 || status.LastError == "" {
+		t.Errorf("Expected the failed reload in the status, got %+v", status)
+	}
+}
+
+func TestWatchReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "servers:\n  - address: \"http://localhost:8081\"\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config, %v", err)
+	}
+	config, _ := Load(path)
+	lb, _ := config.NewBalancer()
+	reloader := NewReloader(path, config, lb, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond)
+	if reloader.Status().Reloads != 0 {
+		t.Errorf("Expected no reload while the file is unchanged")
+	}
+
+	content += "load_balancing_algorithm: \"least-connections\"\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config, %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for lb.GetAlgorithm() != "least-connections" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the changed file to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	//create temporary config file details
+	configContent := `servers:
+	- address: "http://localhost:8081"
+	- address: "http://localhost:8082"
+	- address: "http://localhost:8083"
+	health_check_interval:15
+	load_balancing_algorithm: "least-connections"`
+
+	tmpFile := "/tmp/test_config.yaml"
+	err := os.WriteFile(tmpFile, []byte(configContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write test config, %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	config, err := Load(tmpFile)
+	if err != nil {
+		t.Errorf("Failed to load the config file, %v", err)
+	}
+
+	if len(config.Servers) != 3 {
+		t.Errorf("Expected 3 servers, got %d", len(config.Servers))
+	}
+
+	if config.HealthCheckIntervals != 15 {
+		t.Errorf("Expected health check interval of 15, got %d", config.HealthCheckIntervals)
+	}
+
+	if config.LoadBalancingAlgo != "least-connections" {
+		t.Errorf("Expected least-connections as the algorithm, got %s", config.LoadBalancingAlgo)
+	}
+
+	//test expected server addresses
+	expectedAddresses := []string{
+		"http://localhost:8081",
+		"http://localhost:8082",
+		"http://localhost:8083",
+	}
+
+	for i, expected := range expectedAddresses {
+		if config.Servers[i].Address != expected {
+			t.Errorf("Expected server %d address %s, got %s", i, expected, config.Servers[i].Address)
+		}
+	}
+}
+
+func TestLoadConfigDefault(t *testing.T) {
+	//create config file with minimum details
+	configContent := `servers:
+	- address: "http://localhost:8081"`
+
+	tmpfile := "/tmp/test_config_defaults.yaml"
+	err := os.WriteFile(tmpfile, []byte(configContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write test config, %v", err)
+	}
+	defer os.Remove(tmpfile)
+
+	config, err := Load(tmpfile)
+	if err != nil {
+		t.Fatalf("Failed to load the config file, %v", err)
+	}
+
+	//check if defaults are applied
+	if config.HealthCheckIntervals != 10 {
+		t.Errorf("Expected health check interval of 10, got %d", config.HealthCheckIntervals)
+	}
+
+	if config.LoadBalancingAlgo != "round-robin" {
+		t.Errorf("Expected round-robin as the default algorithm, got %s", config.LoadBalancingAlgo)
+	}
+}
+
+func TestLoadConfigError(t *testing.T) {
+	//test non existent file
+	_, err := Load("non-existent-file.yaml")
+	if err == nil {
+		t.Errorf("Expected error for non existent file")
+	}
+
+	//test invalid yaml
+	invalidContent := `servers:
+	- address: "http://localhost:8081"
+	invalid_yaml_content:[`
+
+	tmpFile := "/tmp/test_config_invalid.yaml"
+	err = os.WriteFile(tmpFile, []byte(invalidContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write invalid config, %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	_, err = Load(tmpFile)
+	if err != nil {
+		t.Errorf("Expected error for invalid yaml")
+	}
+}
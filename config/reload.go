@@ -0,0 +1,213 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/admin"
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+	"github.com/SusheelSathyaraj/go-load-balancer/health"
+)
+
+// Reloader applies changes of the config file to the running balancer and
+// health checker, the servers, algorithm, balancing settings and health checks
+// change live while the proxy, forwarding, websocket, retry, admin, reload and
+// ewma settings keep their values until a restart
+type Reloader struct {
+	Path     string
+	Balancer *balancer.Balancer
+	Checker  *health.Checker
+	Prepare  func(*Config) error //applied to every loaded config before it is checked, e.g. command-line overrides
+
+	mutex   sync.Mutex
+	current *Config
+	status  admin.ReloadStatus
+}
+
+// NewReloader creates a reloader for the balancer and checker built from cfg, which was loaded from path
+func NewReloader(path string, cfg *Config, lb *balancer.Balancer, checker *health.Checker) *Reloader {
+	return &Reloader{
+		Path:     path,
+		Balancer: lb,
+		Checker:  checker,
+		current:  cfg,
+		status:   admin.ReloadStatus{LastStatus: "none"},
+	}
+}
+
+// Reload reads the config file again and applies it, an invalid config is
+// rejected as a whole and the running config is kept
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.status.LastReload = &now
+	next, err := Load(r.Path)
+	if err == nil && r.Prepare != nil {
+		err = r.Prepare(next)
+	}
+	if err == nil {
+		err = r.apply(next)
+	}
+	if err != nil {
+		r.status.Failures++
+		r.status.LastStatus = "failed"
+		r.status.LastError = err.Error()
+		log.Printf("Error: rejected the new config, keeping the running config: %v", err)
+		return err
+	}
+
+	r.current = next
+	r.status.Reloads++
+	r.status.LastStatus = "ok"
+	r.status.LastError = ""
+	log.Printf("Reloaded the config from %s", r.Path)
+	return nil
+}
+
+// Status returns the reload counters and the outcome of the last reload
+func (r *Reloader) Status() admin.ReloadStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.status
+}
+
+// Watch reloads the config whenever the file's modification time or size
+// changes, polling it at the interval until the context is cancelled
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	last, err := os.Stat(r.Path)
+	if err != nil {
+		log.Printf("Error: watching the config file: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(r.Path)
+		if err != nil {
+			//the file can be missing for a moment while an editor replaces it
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		log.Printf("Config file %s changed, reloading", r.Path)
+		r.Reload()
+	}
+}
+
+// applying the new config, everything that can fail is checked before the
+// running balancer and checker are changed
+func (r *Reloader) apply(next *Config) error {
+	checker, err := next.NewChecker()
+	if err != nil {
+		return fmt.Errorf("invalid health check config: %v", err)
+	}
+
+	lb := r.Balancer
+	wanted := make(map[string]ServerConfig, len(next.Servers))
+	var added []*balancer.Server
+	for _, srv := range next.Servers {
+		if _, ok := wanted[srv.Address]; ok {
+			continue
+		}
+		wanted[srv.Address] = srv
+		if r.activeServer(srv.Address) != nil {
+			continue
+		}
+		server, err := balancer.NewServer(srv.Address)
+		if err != nil {
+			return err
+		}
+		server.Weight = srv.Weight
+		added = append(added, server)
+	}
+
+	//the new config is valid from here on
+	for _, server := range lb.GetServers() {
+		if server.IsDraining() {
+			continue
+		}
+		srv, ok := wanted[server.Address]
+		if !ok {
+			//draining lets the requests in flight complete before the server is removed
+			if err := lb.DrainServer(server.Address, 0); err != nil {
+				log.Printf("Error: draining removed server %s: %v", server.Address, err)
+			}
+			continue
+		}
+		if server.GetWeight() != srv.Weight {
+			server.SetWeight(srv.Weight)
+			log.Printf("Changed the weight of server %s to %d", server.Address, srv.Weight)
+		}
+	}
+	for _, server := range added {
+		//the server starts unhealthy and joins the rotation after its first health check
+		if err := lb.AddServer(server); err != nil {
+			log.Printf("Error: adding server %s: %v", server.Address, err)
+		}
+	}
+
+	if next.LoadBalancingAlgo != lb.GetAlgorithm() {
+		lb.SetAlgorithm(next.LoadBalancingAlgo)
+	}
+	if next.ConsistentHash != r.current.ConsistentHash {
+		lb.ConfigureConsistentHash(next.ConsistentHash.Key, next.ConsistentHash.Name, next.ConsistentHash.VirtualNodes)
+	}
+	if next.MaglevTableSize != r.current.MaglevTableSize {
+		lb.ConfigureMaglev(next.MaglevTableSize)
+	}
+	lb.ConfigureOutlierDetection(next.OutlierDetection.OutlierDetection())
+	lb.ConfigureCircuitBreaker(next.CircuitBreaker.CircuitBreaker())
+	lb.ConfigureSlowStart(next.SlowStart.SlowStart())
+	drainTimeout := balancer.DefaultDrainTimeout
+	if next.DrainTimeout > 0 {
+		drainTimeout = time.Duration(next.DrainTimeout) * time.Second
+	}
+	lb.ConfigureDrainTimeout(drainTimeout)
+
+	if r.Checker != nil {
+		r.Checker.Configure(checker.Check, checker.Servers, next.HealthCheckInterval())
+	}
+
+	if r.needsRestart(next) {
+		log.Printf("Warning: proxy, forwarding, websocket, retry, admin, reload or ewma settings changed, they take effect after a restart")
+	}
+	return nil
+}
+
+// getting the server with the address that is not draining, nil if there is none
+func (r *Reloader) activeServer(address string) *balancer.Server {
+	for _, server := range r.Balancer.GetServers() {
+		if server.Address == address && !server.IsDraining() {
+			return server
+		}
+	}
+	return nil
+}
+
+// checking if settings that are only read at startup changed
+func (r *Reloader) needsRestart(next *Config) bool {
+	current := r.current
+	return current.Proxy != next.Proxy ||
+		!reflect.DeepEqual(current.Forwarding, next.Forwarding) ||
+		current.WebSocket != next.WebSocket ||
+		current.Retry.RetryPolicy() != next.Retry.RetryPolicy() ||
+		current.Admin != next.Admin ||
+		current.Reload != next.Reload ||
+		current.EWMAHalfLife != next.EWMAHalfLife
+}
@@ -2,4 +2,15 @@ module github.com/SusheelSathyaraj/go-load-balancer
 
 go 1.21.6
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	google.golang.org/grpc v1.67.3
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
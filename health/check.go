@@ -0,0 +1,322 @@
+package health
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// most of the response body that is read when matching the body
+const maxCheckBodyBytes = 64 * 1024
+
+// range of accepted status codes, inclusive
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// kinds of active health check
+const (
+	CheckHTTP = "http" //request a path and match the response
+	CheckTCP  = "tcp"  //a connection can be opened
+	CheckTLS  = "tls"  //a tls handshake succeeds
+	CheckGRPC = "grpc" //grpc.health.v1.Health/Check answers SERVING
+)
+
+// settings for the active health check request
+type CheckConfig struct {
+	Type             string //http, tcp, tls or grpc, empty means http
+	Path             string
+	Method           string
+	Headers          http.Header
+	Host             string //Host header sent instead of the server address, empty keeps it
+	Timeout          time.Duration
+	ExpectedStatuses []StatusRange
+	BodyContains     string         //the body must contain this text, empty skips the check
+	BodyRegex        *regexp.Regexp //the body must match this expression, nil skips the check
+
+	Interval   time.Duration //time between probes, zero uses the checker's interval
+	MaxBackoff time.Duration //upper bound for the growing interval of an unhealthy server
+
+	HealthyThreshold   int //passes in a row needed to mark an unhealthy server healthy
+	UnhealthyThreshold int //failures in a row needed to mark a healthy server unhealthy
+
+	Service string //grpc checks only, service name asked about, empty asks about the whole server
+
+	//tls checks and grpc checks of https servers
+	VerifyCertificate bool           //the certificate must be valid for ServerName and not expired
+	ServerName        string         //name sent in SNI and verified, empty uses the server host
+	RootCAs           *x509.CertPool //authorities trusted when verifying, nil uses the system pool
+	CertExpiryWarning time.Duration  //warn when the certificate expires within this time
+}
+
+// health check used when none is configured, GET /health expecting 200
+func DefaultCheckConfig() CheckConfig {
+	return CheckConfig{
+		Path:             "/health",
+		Method:           http.MethodGet,
+		Timeout:          5 * time.Second,
+		ExpectedStatuses: []StatusRange{{Min: http.StatusOK, Max: http.StatusOK}},
+
+		MaxBackoff:         time.Minute,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+
+		CertExpiryWarning: 14 * 24 * time.Hour,
+	}
+}
+
+// parsing accepted statuses given as a code (200), a range (200-299) or a class (2xx)
+func ParseStatusRanges(entries []string) ([]StatusRange, error) {
+	var ranges []StatusRange
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if len(entry) == 3 && strings.EqualFold(entry[1:], "xx") {
+			class, err := strconv.Atoi(entry[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("invalid status class %s", entry)
+			}
+			ranges = append(ranges, StatusRange{Min: class * 100, Max: class*100 + 99})
+			continue
+		}
+
+		first, last, isRange := strings.Cut(entry, "-")
+		low, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %s", entry)
+		}
+		high := low
+		if isRange {
+			high, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil {
+				return nil, fmt.Errorf("invalid status range %s", entry)
+			}
+		}
+		if low < 100 || high > 599 || low > high {
+			return nil, fmt.Errorf("invalid status range %s", entry)
+		}
+		ranges = append(ranges, StatusRange{Min: low, Max: high})
+	}
+	return ranges, nil
+}
+
+// checking if the status code is accepted
+func (cc CheckConfig) acceptsStatus(code int) bool {
+	for _, r := range cc.ExpectedStatuses {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// Checker runs the active health checks, servers use the default check
+// unless they have an override of their own
+type Checker struct {
+	Check   CheckConfig            //check for servers without an override
+	Servers map[string]CheckConfig //overrides by server address
+
+	Jitter float64 //share of the interval each probe is moved by at random, spreading the probes out
+
+	client *http.Client
+
+	warnMutex sync.Mutex
+	warned    map[string]time.Time //certificate expiry already warned about by server address
+
+	loopMutex sync.Mutex
+	loops     map[*balancer.Server]*probeLoop //running probe loop of each server
+
+	grpcMutex   sync.Mutex
+	grpcClients map[*balancer.Server]*grpcClient //grpc clients reused across probes
+
+	configMutex sync.RWMutex  //guards Check, Servers and interval once the checker runs
+	interval    time.Duration //time between probes for servers without an interval of their own
+	configured  chan struct{} //closed when Configure replaces the checks
+}
+
+// NewChecker creates a checker using the check for every server
+func NewChecker(check CheckConfig) *Checker {
+	return &Checker{
+		Check:   check,
+		Servers: make(map[string]CheckConfig),
+		client:  &http.Client{},
+		Jitter:  0.2,
+		warned:  make(map[string]time.Time),
+		loops:   make(map[*balancer.Server]*probeLoop),
+
+		grpcClients: make(map[*balancer.Server]*grpcClient),
+	}
+}
+
+// replacing the checks and the interval of a running checker, every probe
+// loop probes at once with the new checks and keeps the new interval
+func (c *Checker) Configure(check CheckConfig, servers map[string]CheckConfig, interval time.Duration) {
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+
+	c.Check = check
+	c.Servers = servers
+	if interval > 0 {
+		c.interval = interval
+	}
+	if c.configured != nil {
+		close(c.configured)
+		c.configured = nil
+	}
+}
+
+// channel that is closed the next time Configure is called
+func (c *Checker) configChanged() <-chan struct{} {
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+
+	if c.configured == nil {
+		c.configured = make(chan struct{})
+	}
+	return c.configured
+}
+
+// getting the interval for servers without an interval of their own
+func (c *Checker) defaultInterval() time.Duration {
+	c.configMutex.RLock()
+	defer c.configMutex.RUnlock()
+	return c.interval
+}
+
+// setting the interval for servers without an interval of their own
+func (c *Checker) setDefaultInterval(interval time.Duration) {
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+	c.interval = interval
+}
+
+// getting the check for the server, the override takes precedence
+func (c *Checker) checkFor(server *balancer.Server) CheckConfig {
+	c.configMutex.RLock()
+	defer c.configMutex.RUnlock()
+
+	if check, ok := c.Servers[server.Address]; ok {
+		return check
+	}
+	return c.Check
+}
+
+// performing health check on all the servers concurrently
+func (c *Checker) checkAll(servers []*balancer.Server) {
+	var wg sync.WaitGroup
+
+	for _, server := range servers {
+		wg.Add(1)
+		go func(s *balancer.Server) {
+			defer wg.Done()
+			c.checkServer(s)
+		}(server)
+	}
+	wg.Wait()
+}
+
+// performing health check on a single server, its status changes once the
+// threshold of results in a row is reached
+func (c *Checker) checkServer(server *balancer.Server) {
+	c.checkServerContext(context.Background(), server)
+}
+
+// performing health check on a single server, a probe cut short by the context
+// is not counted
+func (c *Checker) checkServerContext(ctx context.Context, server *balancer.Server) {
+	check := c.checkFor(server)
+	err := c.probe(ctx, server, check)
+	if ctx.Err() != nil {
+		return
+	}
+
+	healthy, unhealthy := max(check.HealthyThreshold, 1), max(check.UnhealthyThreshold, 1)
+	if err != nil {
+		if server.RecordHealthCheck(false, err.Error(), healthy, unhealthy) {
+			log.Printf("Warning: server %s is unhealthy, %v", server.Address, err)
+		}
+	} else {
+		if server.RecordHealthCheck(true, "health check passed", healthy, unhealthy) {
+			log.Printf("Server %s is healthy", server.Address)
+		}
+	}
+}
+
+// probing the server with the configured kind of check, nil means the server passed
+func (c *Checker) probe(ctx context.Context, server *balancer.Server, check CheckConfig) error {
+	if check.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, check.Timeout)
+		defer cancel()
+	}
+
+	switch check.Type {
+	case CheckTCP:
+		return c.probeTCP(ctx, server)
+	case CheckTLS:
+		return c.probeTLS(ctx, server, check)
+	case CheckGRPC:
+		return c.probeGRPC(ctx, server, check)
+	}
+	return c.probeHTTP(ctx, server, check)
+}
+
+// sending the health check request and matching the response
+func (c *Checker) probeHTTP(ctx context.Context, server *balancer.Server, check CheckConfig) error {
+
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(server.Address, "/")+check.Path, nil)
+	if err != nil {
+		return err
+	}
+	for header, values := range check.Headers {
+		for _, value := range values {
+			req.Header.Add(header, value)
+		}
+	}
+	if check.Host != "" {
+		req.Host = check.Host
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !check.acceptsStatus(resp.StatusCode) {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxCheckBodyBytes))
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if check.BodyContains == "" && check.BodyRegex == nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxCheckBodyBytes))
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBodyBytes))
+	if err != nil {
+		return fmt.Errorf("reading body: %v", err)
+	}
+	if check.BodyContains != "" && !strings.Contains(string(body), check.BodyContains) {
+		return fmt.Errorf("body does not contain %q", check.BodyContains)
+	}
+	if check.BodyRegex != nil && !check.BodyRegex.Match(body) {
+		return fmt.Errorf("body does not match %s", check.BodyRegex)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// getting the host:port to dial for the server, the port defaults by scheme
+func dialAddress(server *balancer.Server) (string, error) {
+	serverURL := server.URL
+	if serverURL == nil {
+		parsed, err := url.Parse(server.Address)
+		if err != nil {
+			return "", err
+		}
+		serverURL = parsed
+	}
+	if serverURL.Hostname() == "" {
+		return "", fmt.Errorf("no host in server address %s", server.Address)
+	}
+
+	port := serverURL.Port()
+	if port == "" {
+		port = "80"
+		if serverURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(serverURL.Hostname(), port), nil
+}
+
+// opening a connection to the server and closing it again
+func (c *Checker) probeTCP(ctx context.Context, server *balancer.Server) error {
+	address, err := dialAddress(server)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// completing a tls handshake with the server, recording the expiry of its certificate
+func (c *Checker) probeTLS(ctx context.Context, server *balancer.Server, check CheckConfig) error {
+	address, err := dialAddress(server)
+	if err != nil {
+		return err
+	}
+	serverName := check.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(address)
+	}
+
+	dialer := tls.Dialer{Config: &tls.Config{
+		ServerName:         serverName,
+		RootCAs:            check.RootCAs,
+		InsecureSkipVerify: !check.VerifyCertificate,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+	expiry := certs[0].NotAfter
+	server.SetCertExpiry(expiry)
+	c.warnCertExpiry(server, expiry, check.CertExpiryWarning)
+	return nil
+}
+
+// logging once per certificate when it expires within the warning time
+func (c *Checker) warnCertExpiry(server *balancer.Server, expiry time.Time, warning time.Duration) {
+	remaining := time.Until(expiry)
+	if warning <= 0 || remaining > warning {
+		return
+	}
+
+	c.warnMutex.Lock()
+	defer c.warnMutex.Unlock()
+	if c.warned[server.Address].Equal(expiry) {
+		return
+	}
+	c.warned[server.Address] = expiry
+
+	if remaining <= 0 {
+		log.Printf("Warning: certificate of server %s expired on %s", server.Address, expiry.Format(time.RFC3339))
+		return
+	}
+	log.Printf("Warning: certificate of server %s expires in %v on %s", server.Address, remaining.Round(time.Hour), expiry.Format(time.RFC3339))
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// calling grpc.health.v1.Health/Check on the server, only SERVING passes
+func (c *Checker) probeGRPC(ctx context.Context, server *balancer.Server, check CheckConfig) error {
+	address, err := dialAddress(server)
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.grpcConn(server, address, check)
+	if err != nil {
+		return err
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: check.Service})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status %s", resp.GetStatus())
+	}
+	return nil
+}
+
+// grpc client kept for a server between probes
+type grpcClient struct {
+	conn     *grpc.ClientConn
+	settings grpcSettings
+}
+
+// connection settings of a grpc client, the client is replaced when they change
+type grpcSettings struct {
+	address    string
+	tls        bool
+	serverName string
+	rootCAs    *x509.CertPool
+	verify     bool
+}
+
+// getting the server's grpc client, created on first use, https servers are
+// called over tls and the others over h2c
+func (c *Checker) grpcConn(server *balancer.Server, address string, check CheckConfig) (*grpc.ClientConn, error) {
+	settings := grpcSettings{address: address, tls: server.URL != nil && server.URL.Scheme == "https"}
+	if settings.tls {
+		settings.serverName = check.ServerName
+		settings.rootCAs = check.RootCAs
+		settings.verify = check.VerifyCertificate
+	}
+
+	c.grpcMutex.Lock()
+	defer c.grpcMutex.Unlock()
+
+	if client, ok := c.grpcClients[server]; ok {
+		if client.settings == settings {
+			return client.conn, nil
+		}
+		client.conn.Close()
+		delete(c.grpcClients, server)
+	}
+
+	creds := insecure.NewCredentials()
+	if settings.tls {
+		creds = credentials.NewTLS(&tls.Config{
+			ServerName:         settings.serverName,
+			RootCAs:            settings.rootCAs,
+			InsecureSkipVerify: !settings.verify,
+		})
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	c.grpcClients[server] = &grpcClient{conn: conn, settings: settings}
+	return conn, nil
+}
+
+// closing the grpc client of a server that is no longer probed
+func (c *Checker) closeGRPCClient(server *balancer.Server) {
+	c.grpcMutex.Lock()
+	defer c.grpcMutex.Unlock()
+
+	if client, ok := c.grpcClients[server]; ok {
+		client.conn.Close()
+		delete(c.grpcClients, server)
+	}
+}
@@ -1,78 +1,37 @@
-package main
+// Package health runs active health checks against balancer servers and
+// reports on their health status.
+package health
 
 import (
 	"context"
 	"log"
-	"net/http"
-	"sync"
 	"time"
-)
-
-// performing periodic health checks
-func HealthCheck(servers []*Server, interval time.Duration, ctx context.Context) {
-	log.Printf("Starting health checks with %v interval", interval)
 
-	//Initial Health Checks
-	checkAllServers(servers)
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// default checker, used by the package level functions
+var defaultChecker = NewChecker(DefaultCheckConfig())
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Stopping health checks")
-			return
-		case <-ticker.C:
-			checkAllServers(servers)
-		}
-	}
+// HealthCheck probes the servers every interval with the default check until
+// the context is cancelled, marking each one healthy or unhealthy, every call
+// gets its own checker so pools checked side by side keep their probes
+func HealthCheck(servers []*balancer.Server, interval time.Duration, ctx context.Context) {
+	NewChecker(DefaultCheckConfig()).Run(servers, interval, ctx)
 }
 
 // performing health check on all the servers concurrently
-func checkAllServers(servers []*Server) {
-	var wg sync.WaitGroup
-
-	for _, server := range servers {
-		wg.Add(1)
-		go func(s *Server) {
-			defer wg.Done()
-			checkserverHealth(s)
-		}(server)
-	}
-	wg.Wait()
+func checkAllServers(servers []*balancer.Server) {
+	defaultChecker.checkAll(servers)
 }
 
 // performing health check on a single server
-func checkserverHealth(server *Server) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	//send http get request to the server's health endpoint
-	resp, err := client.Get(server.Address + "/health")
-
-	server.Mutex.Lock()
-	defer server.Mutex.Unlock()
-
-	previousHealth := server.IsHealthy
-
-	if err != nil || resp.StatusCode != http.StatusOK {
-		server.IsHealthy = false
-		if previousHealth {
-			log.Printf("Server %s is unhealthy ,%v", server.Address, err)
-		}
-	} else {
-		server.IsHealthy = true
-		if !previousHealth {
-			log.Printf("Server %s is healthy", server.Address)
-		}
-		resp.Body.Close()
-	}
+func checkserverHealth(server *balancer.Server) {
+	defaultChecker.checkServer(server)
 }
 
 // performing a one-time health check on all servers
-func PerformSingleHealthCheck(servers []*Server) {
+func PerformSingleHealthCheck(servers []*balancer.Server) {
 	log.Println("Performing initial health check...")
 	checkAllServers(servers)
 
@@ -89,7 +48,7 @@ func PerformSingleHealthCheck(servers []*Server) {
 }
 
 // Returning the helath status of all the servers
-func GetHealthStatus(servers []*Server) map[string]bool {
+func GetHealthStatus(servers []*balancer.Server) map[string]bool {
 	status := make(map[string]bool)
 
 	for _, server := range servers {
@@ -101,7 +60,7 @@ func GetHealthStatus(servers []*Server) map[string]bool {
 }
 
 // Checking if atleast one server is healthy
-func IsAnyServerHealthy(servers []*Server) bool {
+func IsAnyServerHealthy(servers []*balancer.Server) bool {
 	for _, server := range servers {
 		server.Mutex.Lock()
 		isHealthy := server.IsHealthy
@@ -115,8 +74,8 @@ func IsAnyServerHealthy(servers []*Server) bool {
 }
 
 // getting a list of healthy servers
-func GetHealthyServers(servers []*Server) []*Server {
-	var healthy []*Server
+func GetHealthyServers(servers []*balancer.Server) []*balancer.Server {
+	var healthy []*balancer.Server
 
 	for _, server := range servers {
 		server.Mutex.Lock()
@@ -129,7 +88,7 @@ func GetHealthyServers(servers []*Server) []*Server {
 }
 
 // getting the number of healthy and unhealthy servers in a single pass
-func GetServerCount(servers []*Server) (healhty, unhealthy int) {
+func GetServerCount(servers []*balancer.Server) (healhty, unhealthy int) {
 	healthy, unhealthy := 0, 0
 	for _, server := range servers {
 		server.Mutex.Lock()
@@ -144,8 +103,8 @@ func GetServerCount(servers []*Server) (healhty, unhealthy int) {
 }
 
 // getting a list of unhealthy servers
-func GetUnhealthyServers(servers []*Server) []*Server {
-	var unhealthy []*Server
+func GetUnhealthyServers(servers []*balancer.Server) []*balancer.Server {
+	var unhealthy []*balancer.Server
 
 	for _, server := range servers {
 		server.Mutex.Lock()
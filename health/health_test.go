@@ -0,0 +1,773 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+//Test Helper function
+
+// creates a  test HTTP server with customisable responses
+func createMockServer(response string, statusCode int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if r.URL.Path == "/health" {
+			if statusCode == http.StatusOK {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("healthy"))
+			} else {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("unhealthy"))
+			}
+			return
+		}
+		w.WriteHeader(statusCode)
+		w.Write([]byte(response))
+	}))
+}
+
+// creates a set of test servers
+func createTestServers(count int, healthy bool) ([]*balancer.Server, []*httptest.Server) {
+	servers := make([]*balancer.Server, count)
+	testServers := make([]*httptest.Server, count)
+
+	for i := 0; i < count; i++ {
+		statusCode := http.StatusOK
+		if !healthy {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		testServer := createMockServer(fmt.Sprintf("server %d", i+1), statusCode, 0)
+		testServers[i] = testServer
+
+		serverURL, _ := url.Parse(testServer.URL)
+		servers[i] = &balancer.Server{
+			Address:   testServer.URL,
+			IsHealthy: healthy,
+			URL:       serverURL,
+		}
+	}
+	return servers, testServers
+}
+
+// cleanup closes all test servers
+func cleanup(testServers []*httptest.Server) {
+	for _, server := range testServers {
+		server.Close()
+	}
+}
+
+//Test Health Checking
+
+func TestHealthCheck(t *testing.T) {
+	//Create servers, one healthy and one unhealthy
+	healthyServer := createMockServer("healthy", http.StatusOK, 0)
+	unhealthyServer := createMockServer("unhealthy", http.StatusServiceUnavailable, 0)
+	defer healthyServer.Close()
+	defer unhealthyServer.Close()
+
+	url1, _ := url.Parse(healthyServer.URL)
+	url2, _ := url.Parse(unhealthyServer.URL)
+
+	servers := []*balancer.Server{
+		{Address: healthyServer.URL, IsHealthy: false, URL: url1},  //initially set to false
+		{Address: unhealthyServer.URL, IsHealthy: true, URL: url2}, //initially set to true
+	}
+
+	//run single health check
+	checkAllServers(servers)
+
+	//check results
+	if !servers[0].IsHealthy {
+		t.Error("Expected first server to be healthy after health check")
+	}
+	if servers[1].IsHealthy {
+		t.Error("Expected second server to be unhealthy after the health check")
+	}
+}
+
+func TestHealthCheckWithTimeout(t *testing.T) {
+	//create slow server that takea longer than health check timeout
+	slowServer := createMockServer("slow", http.StatusOK, 10*time.Second)
+	defer slowServer.Close()
+
+	slowURL, _ := url.Parse(slowServer.URL)
+	servers := []*balancer.Server{
+		{Address: slowServer.URL, IsHealthy: true, URL: slowURL},
+	}
+
+	//run health check (should timeout and mark as unhealthy)
+	start := time.Now()
+	checkAllServers(servers)
+	duration := time.Since(start)
+
+	//Should complete quickly due to timeout
+	if duration > 7*time.Second {
+		t.Errorf("Health check took too long: %v", duration)
+	}
+
+	if servers[0].IsHealthy {
+		t.Errorf("Expected slow server to be marked unhealthy due timeout")
+	}
+}
+
+// creates a backend with the kinds of health endpoints our services expose
+func createHealthEndpoints() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			if r.Host != "svc.internal" || r.Header.Get("X-Health-Check") != "1" {
+				w.WriteHeader(http.StatusMisdirectedRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/probe":
+			if r.Method != http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/status":
+			w.Write([]byte("status: ok version=2"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestConfigurableHealthCheck(t *testing.T) {
+	backend := createHealthEndpoints()
+	defer backend.Close()
+
+	healthz := DefaultCheckConfig()
+	healthz.Path = "/healthz"
+	healthz.Host = "svc.internal"
+	healthz.Headers = http.Header{"X-Health-Check": []string{"1"}}
+	healthz.ExpectedStatuses = []StatusRange{{Min: 200, Max: 299}}
+
+	withChanges := func(change func(*CheckConfig)) CheckConfig {
+		check := healthz
+		change(&check)
+		return check
+	}
+
+	tests := []struct {
+		name     string
+		check    CheckConfig
+		expected bool
+	}{
+		{"Default check", DefaultCheckConfig(), false},
+		{"Path, Host and headers", healthz, true},
+		{"Missing Host", withChanges(func(c *CheckConfig) { c.Host = "" }), false},
+		{"Missing header", withChanges(func(c *CheckConfig) { c.Headers = nil }), false},
+		{"Status not accepted", withChanges(func(c *CheckConfig) { c.ExpectedStatuses = []StatusRange{{Min: 200, Max: 200}} }), false},
+		{"Method", withChanges(func(c *CheckConfig) { c.Path = "/probe"; c.Method = http.MethodHead }), true},
+		{"Wrong method", withChanges(func(c *CheckConfig) { c.Path = "/probe" }), false},
+		{"Body contains", withChanges(func(c *CheckConfig) { c.Path = "/status"; c.BodyContains = "status: ok" }), true},
+		{"Body does not contain", withChanges(func(c *CheckConfig) { c.Path = "/status"; c.BodyContains = "degraded" }), false},
+		{"Body regex", withChanges(func(c *CheckConfig) { c.Path = "/status"; c.BodyRegex = regexp.MustCompile(`version=\d+`) }), true},
+		{"Body regex mismatch", withChanges(func(c *CheckConfig) { c.Path = "/status"; c.BodyRegex = regexp.MustCompile(`version=[a-z]+`) }), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := balancer.NewServer(backend.URL)
+			server.SetHealthy(!tt.expected)
+
+			NewChecker(tt.check).checkServer(server)
+
+			if server.IsServerHealthy() != tt.expected {
+				t.Errorf("Expected healthy to be %v, got %v", tt.expected, server.IsServerHealthy())
+			}
+		})
+	}
+}
+
+func TestHealthCheckServerOverride(t *testing.T) {
+	backend := createHealthEndpoints()
+	defer backend.Close()
+
+	//two addresses for the same backend so only the override differs
+	defaultServer, _ := balancer.NewServer(backend.URL)
+	overrideServer, _ := balancer.NewServer(strings.Replace(backend.URL, "127.0.0.1", "localhost", 1))
+
+	override := DefaultCheckConfig()
+	override.Path = "/status"
+	checker := NewChecker(DefaultCheckConfig())
+	checker.Servers[overrideServer.Address] = override
+
+	checker.checkAll([]*balancer.Server{defaultServer, overrideServer})
+
+	if defaultServer.IsServerHealthy() {
+		t.Errorf("Expected the server without an override to use the default /health check")
+	}
+	if !overrideServer.IsServerHealthy() {
+		t.Errorf("Expected the server override to take precedence over the default check")
+	}
+}
+
+func TestHealthCheckThresholds(t *testing.T) {
+	var mutex sync.Mutex
+	status := http.StatusOK
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer backend.Close()
+	setStatus := func(code int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		status = code
+	}
+
+	check := DefaultCheckConfig()
+	check.HealthyThreshold = 2
+	check.UnhealthyThreshold = 3
+	checker := NewChecker(check)
+	server, _ := balancer.NewServer(backend.URL)
+
+	//the first check decides at once
+	checker.checkServer(server)
+	if !server.IsServerHealthy() {
+		t.Fatalf("Expected the first passing check to mark the server healthy")
+	}
+
+	//failures short of the threshold, broken by a pass, keep it healthy
+	setStatus(http.StatusServiceUnavailable)
+	checker.checkServer(server)
+	checker.checkServer(server)
+	setStatus(http.StatusOK)
+	checker.checkServer(server)
+	setStatus(http.StatusServiceUnavailable)
+	checker.checkServer(server)
+	checker.checkServer(server)
+	if !server.IsServerHealthy() {
+		t.Errorf("Expected the server to stay healthy below the unhealthy threshold")
+	}
+	if streak := server.GetHealthStreak(); streak != -2 {
+		t.Errorf("Expected a streak of -2, got %d", streak)
+	}
+
+	checker.checkServer(server)
+	if server.IsServerHealthy() {
+		t.Errorf("Expected the server to be unhealthy after 3 failures in a row")
+	}
+	transitioned, reason := server.GetLastTransition()
+	if transitioned.IsZero() || !strings.Contains(reason, "unexpected status 503") || !strings.Contains(reason, "3 in a row") {
+		t.Errorf("Expected the transition to be recorded with its reason, got %v %q", transitioned, reason)
+	}
+
+	setStatus(http.StatusOK)
+	checker.checkServer(server)
+	if server.IsServerHealthy() {
+		t.Errorf("Expected the server to stay unhealthy below the healthy threshold")
+	}
+	checker.checkServer(server)
+	if !server.IsServerHealthy() {
+		t.Errorf("Expected the server to be healthy after 2 passes in a row")
+	}
+	if _, reason := server.GetLastTransition(); reason != "health check passed (2 in a row)" {
+		t.Errorf("Expected reason %q, got %q", "health check passed (2 in a row)", reason)
+	}
+}
+
+func TestTCPAndTLSHealthChecks(t *testing.T) {
+	//a tls backend without any health path, only the connection matters
+	tlsBackend := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsBackend.Close()
+	plainBackend := httptest.NewServer(http.NotFoundHandler())
+	defer plainBackend.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	trusted := x509.NewCertPool()
+	trusted.AddCert(tlsBackend.Certificate())
+
+	withType := func(checkType string, change func(*CheckConfig)) CheckConfig {
+		check := DefaultCheckConfig()
+		check.Type = checkType
+		check.Timeout = time.Second
+		if change != nil {
+			change(&check)
+		}
+		return check
+	}
+
+	tests := []struct {
+		name     string
+		address  string
+		check    CheckConfig
+		expected bool
+	}{
+		{"TCP connect", plainBackend.URL, withType(CheckTCP, nil), true},
+		{"TCP refused", closed.URL, withType(CheckTCP, nil), false},
+		{"TLS handshake", tlsBackend.URL, withType(CheckTLS, nil), true},
+		{"TLS to a plain backend", plainBackend.URL, withType(CheckTLS, nil), false},
+		{"TLS untrusted certificate", tlsBackend.URL, withType(CheckTLS, func(c *CheckConfig) { c.VerifyCertificate = true }), false},
+		{"TLS verified certificate", tlsBackend.URL, withType(CheckTLS, func(c *CheckConfig) { c.VerifyCertificate = true; c.RootCAs = trusted }), true},
+		{"TLS wrong server name", tlsBackend.URL, withType(CheckTLS, func(c *CheckConfig) {
+			c.VerifyCertificate = true
+			c.RootCAs = trusted
+			c.ServerName = "other.internal"
+		}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := balancer.NewServer(tt.address)
+			server.SetHealthy(!tt.expected)
+
+			NewChecker(tt.check).checkServer(server)
+
+			if server.IsServerHealthy() != tt.expected {
+				t.Errorf("Expected healthy to be %v, got %v", tt.expected, server.IsServerHealthy())
+			}
+		})
+	}
+
+	//the certificate expiry is recorded so it can be reported
+	server, _ := balancer.NewServer(tlsBackend.URL)
+	NewChecker(withType(CheckTLS, nil)).checkServer(server)
+	if expiry := server.GetCertExpiry(); !expiry.Equal(tlsBackend.Certificate().NotAfter) {
+		t.Errorf("Expected certificate expiry %v, got %v", tlsBackend.Certificate().NotAfter, expiry)
+	}
+}
+
+// starts an in-process grpc server with the standard health service, over tls when a certificate is given
+func createGRPCBackend(t *testing.T, cert *tls.Certificate) (*grpchealth.Server, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen, %v", err)
+	}
+
+	var opts []grpc.ServerOption
+	scheme := "http"
+	if cert != nil {
+		opts = append(opts, grpc.Creds(credentials.NewServerTLSFromCert(cert)))
+		scheme = "https"
+	}
+	grpcServer := grpc.NewServer(opts...)
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return healthServer, scheme + "://" + listener.Addr().String()
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	h2cHealth, h2cAddress := createGRPCBackend(t, nil)
+	h2cHealth.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	h2cHealth.SetServingStatus("payments", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	//reusing the httptest certificate for the tls backend
+	tlsCert := httptest.NewUnstartedServer(nil)
+	tlsCert.StartTLS()
+	tlsCert.Close()
+	tlsHealth, tlsAddress := createGRPCBackend(t, &tlsCert.TLS.Certificates[0])
+	tlsHealth.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+
+	trusted := x509.NewCertPool()
+	trusted.AddCert(tlsCert.Certificate())
+
+	httpBackend := createHealthEndpoints()
+	defer httpBackend.Close()
+
+	grpcCheck := func(change func(*CheckConfig)) CheckConfig {
+		check := DefaultCheckConfig()
+		check.Type = CheckGRPC
+		check.Timeout = 2 * time.Second
+		if change != nil {
+			change(&check)
+		}
+		return check
+	}
+
+	tests := []struct {
+		name     string
+		address  string
+		check    CheckConfig
+		expected bool
+	}{
+		{"Whole server over h2c", h2cAddress, grpcCheck(nil), true},
+		{"Serving service", h2cAddress, grpcCheck(func(c *CheckConfig) { c.Service = "orders" }), true},
+		{"Not serving service", h2cAddress, grpcCheck(func(c *CheckConfig) { c.Service = "payments" }), false},
+		{"Unknown service", h2cAddress, grpcCheck(func(c *CheckConfig) { c.Service = "unknown" }), false},
+		{"Over tls", tlsAddress, grpcCheck(nil), true},
+		{"Over tls untrusted certificate", tlsAddress, grpcCheck(func(c *CheckConfig) { c.VerifyCertificate = true }), false},
+		{"Over tls verified certificate", tlsAddress, grpcCheck(func(c *CheckConfig) {
+			c.VerifyCertificate = true
+			c.RootCAs = trusted
+			c.ServerName = "example.com"
+		}), true},
+		{"Plain http backend", httpBackend.URL, grpcCheck(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := balancer.NewServer(tt.address)
+			server.SetHealthy(!tt.expected)
+
+			NewChecker(tt.check).checkServer(server)
+
+			if server.IsServerHealthy() != tt.expected {
+				t.Errorf("Expected healthy to be %v, got %v", tt.expected, server.IsServerHealthy())
+			}
+		})
+	}
+}
+
+func TestGRPCClientReused(t *testing.T) {
+	grpcHealth, address := createGRPCBackend(t, nil)
+	grpcHealth.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+
+	check := DefaultCheckConfig()
+	check.Type = CheckGRPC
+	checker := NewChecker(check)
+	server, _ := balancer.NewServer(address)
+
+	checker.checkServer(server)
+	first := checker.grpcClients[server].conn
+	checker.checkServer(server)
+	if checker.grpcClients[server].conn != first {
+		t.Errorf("Expected the grpc client to be reused between probes")
+	}
+	if !server.IsServerHealthy() {
+		t.Errorf("Expected the server to be healthy over the reused client")
+	}
+
+	//the client is closed once the server is no longer probed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	checker.sync(ctx, []*balancer.Server{server})
+	checker.sync(ctx, nil)
+	if _, ok := checker.grpcClients[server]; ok {
+		t.Errorf("Expected the grpc client of a removed server to be closed")
+	}
+	if state := first.GetState(); state != connectivity.Shutdown {
+		t.Errorf("Expected the grpc connection to be shut down, got %s", state)
+	}
+}
+
+// creates a backend counting the health checks it receives
+func createCountingBackend() (*httptest.Server, *atomic.Int64) {
+	var probes atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	return backend, &probes
+}
+
+func TestProbeLoopsFollowPool(t *testing.T) {
+	first, firstProbes := createCountingBackend()
+	defer first.Close()
+	second, secondProbes := createCountingBackend()
+	defer second.Close()
+
+	firstServer, _ := balancer.NewServer(first.URL)
+	secondServer, _ := balancer.NewServer(second.URL)
+	lb := balancer.NewLoadBalancer([]*balancer.Server{firstServer}, "round-robin")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		NewChecker(DefaultCheckConfig()).Watch(lb, 10*time.Millisecond, ctx)
+		close(stopped)
+	}()
+
+	waitFor := func(description string, condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", description)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor("the first server to be probed", func() bool { return firstProbes.Load() >= 3 })
+	if secondProbes.Load() != 0 {
+		t.Errorf("Expected no probes for a server outside the pool, got %d", secondProbes.Load())
+	}
+
+	lb.AddServer(secondServer)
+	waitFor("the added server to be probed", func() bool { return secondProbes.Load() >= 3 && secondServer.IsServerHealthy() })
+
+	lb.RemoveServer(first.URL)
+	//the watcher stops the loop shortly after the pool change
+	time.Sleep(20 * time.Millisecond)
+	removedProbes := firstProbes.Load()
+	time.Sleep(50 * time.Millisecond)
+	if firstProbes.Load() != removedProbes {
+		t.Errorf("Expected probes of a removed server to stop, got %d more", firstProbes.Load()-removedProbes)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected Watch to return once the context is cancelled")
+	}
+	//a probe cancelled in flight can still reach the backend
+	time.Sleep(20 * time.Millisecond)
+	remainingProbes := secondProbes.Load()
+	time.Sleep(50 * time.Millisecond)
+	if secondProbes.Load() != remainingProbes {
+		t.Errorf("Expected every probe loop to stop with the context")
+	}
+}
+
+func TestHealthCheckPoolsSideBySide(t *testing.T) {
+	first, firstProbes := createCountingBackend()
+	defer first.Close()
+	second, secondProbes := createCountingBackend()
+	defer second.Close()
+
+	firstServer, _ := balancer.NewServer(first.URL)
+	secondServer, _ := balancer.NewServer(second.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go HealthCheck([]*balancer.Server{firstServer}, 10*time.Millisecond, ctx)
+	time.Sleep(30 * time.Millisecond)
+	go HealthCheck([]*balancer.Server{secondServer}, 10*time.Millisecond, ctx)
+
+	//checking a second pool leaves the probes of the first one running
+	time.Sleep(30 * time.Millisecond)
+	before := firstProbes.Load()
+	time.Sleep(100 * time.Millisecond)
+	if firstProbes.Load() <= before {
+		t.Errorf("Expected the first pool to keep being probed, got %d probes before and %d after", before, firstProbes.Load())
+	}
+	if secondProbes.Load() == 0 {
+		t.Errorf("Expected the second pool to be probed")
+	}
+}
+
+func TestProbeBackoffAndJitter(t *testing.T) {
+	backend := createMockServer("", http.StatusServiceUnavailable, 0)
+	defer backend.Close()
+
+	check := DefaultCheckConfig()
+	check.UnhealthyThreshold = 1
+	check.MaxBackoff = 8 * time.Second
+	checker := NewChecker(check)
+	checker.Jitter = 0
+	server, _ := balancer.NewServer(backend.URL)
+
+	//each failure while unhealthy doubles the interval up to the max backoff
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for i, want := range expected {
+		checker.checkServer(server)
+		if delay := checker.nextProbe(server, check, time.Second); delay != want {
+			t.Errorf("Expected delay %v after %d failures, got %v", want, i+1, delay)
+		}
+	}
+
+	//a per server interval replaces the checker's interval
+	withInterval := check
+	withInterval.Interval = 3 * time.Second
+	server.SetHealthy(true)
+	if delay := checker.nextProbe(server, withInterval, time.Second); delay != 3*time.Second {
+		t.Errorf("Expected the server interval of 3s for a healthy server, got %v", delay)
+	}
+
+	//jitter spreads the probes around the interval
+	checker.Jitter = 0.5
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		delay := checker.nextProbe(server, withInterval, time.Second)
+		if delay < 1500*time.Millisecond || delay > 4500*time.Millisecond {
+			t.Errorf("Expected the delay within 50%% of 3s, got %v", delay)
+		}
+		seen[delay] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected jitter to vary the delay")
+	}
+}
+
+func TestConfigureRunningChecker(t *testing.T) {
+	backend, probes := createCountingBackend()
+	defer backend.Close()
+
+	server, _ := balancer.NewServer(backend.URL)
+	checker := NewChecker(DefaultCheckConfig())
+	checker.Jitter = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run([]*balancer.Server{server}, time.Hour, ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for probes.Load() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the first probe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if probes.Load() != 1 {
+		t.Fatalf("Expected one probe with an hour interval, got %d", probes.Load())
+	}
+
+	//the loop waiting on the old interval picks up the new one at once
+	check := DefaultCheckConfig()
+	check.Path = "/ready"
+	checker.Configure(check, map[string]CheckConfig{}, 10*time.Millisecond)
+
+	deadline = time.Now().Add(2 * time.Second)
+	for probes.Load() < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for probes at the new interval, got %d", probes.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := checker.checkFor(server).Path; got != "/ready" {
+		t.Errorf("Expected the reconfigured path /ready, got %s", got)
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		entries  []string
+		expected []StatusRange
+		valid    bool
+	}{
+		{[]string{"200"}, []StatusRange{{200, 200}}, true},
+		{[]string{"200-299", "304"}, []StatusRange{{200, 299}, {304, 304}}, true},
+		{[]string{"2xx", "3XX"}, []StatusRange{{200, 299}, {300, 399}}, true},
+		{[]string{"abc"}, nil, false},
+		{[]string{"299-200"}, nil, false},
+		{[]string{"6xx"}, nil, false},
+		{[]string{"700"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		ranges, err := ParseStatusRanges(tt.entries)
+		if (err == nil) != tt.valid {
+			t.Errorf("Expected valid to be %v for %v, got error %v", tt.valid, tt.entries, err)
+			continue
+		}
+		if tt.valid && fmt.Sprint(ranges) != fmt.Sprint(tt.expected) {
+			t.Errorf("Expected %v for %v, got %v", tt.expected, tt.entries, ranges)
+		}
+	}
+}
+
+func TestGetServerCount(t *testing.T) {
+	servers, testServers := createTestServers(5, true)
+	defer cleanup(testServers)
+
+	//make some servers healthy
+	servers[1].IsHealthy = true
+	servers[3].IsHealthy = true
+
+	healthy, unhealthy := GetServerCount(servers)
+
+	if healthy != 2 {
+		t.Errorf("Expected 2 healthy servers, got %d", healthy)
+	}
+	if unhealthy != 3 {
+		t.Errorf("Expected 3 unhealthy servers, got %d", unhealthy)
+	}
+}
+
+func TestGetHealthyUnhealthyServers(t *testing.T) {
+	servers, testServers := createTestServers(4, false)
+	defer cleanup(testServers)
+
+	//make 2 servers healthy
+	servers[1].IsHealthy = true
+	servers[3].IsHealthy = true
+
+	healthyServers := GetHealthyServers(servers)
+	unhealthyServers := GetUnhealthyServers(servers)
+
+	if len(healthyServers) != 2 {
+		t.Errorf("Expected to get 2 healthy servers, got %d", len(healthyServers))
+	}
+	if len(unhealthyServers) != 2 {
+		t.Errorf("Expected to get 3 unhealthy servers, got %d", len(unhealthyServers))
+	}
+
+	//verify correct servers are returned
+	healthyAddresses := []string{healthyServers[1].Address, healthyServers[3].Address}
+	expectedhealthyAddresses := []string{servers[1].Address, servers[3].Address}
+
+	for _, expected := range expectedhealthyAddresses {
+		found := false
+		for _, actual := range healthyAddresses {
+			if actual == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected healthy server %s,not found", expected)
+		}
+	}
+}
+
+// test concurrent operations
+func TestConcurrentHealthChecks(t *testing.T) {
+	servers, testServers := createTestServers(10, true)
+	defer cleanup(testServers)
+
+	//run multiple concurrent health checks
+	var wg sync.WaitGroup
+	numChecks := 50
+
+	for i := 0; i < numChecks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkAllServers(servers)
+		}()
+	}
+	wg.Wait()
+
+	//all servers should remain healthy
+	for i, server := range servers {
+		if !server.IsHealthy {
+			t.Errorf("Server %d should be healthy after concurrent checks", i)
+		}
+	}
+}
+
+//Benchmark tests
+
+func BenchmarkHealthCheck(b *testing.B) {
+	servers, testServers := createTestServers(20, true)
+	defer cleanup(testServers)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		checkAllServers(servers)
+	}
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// probe loop running for one server
+type probeLoop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Run probes each server in its own loop until the context is cancelled,
+// marking each one healthy or unhealthy, interval is used by servers
+// without an interval of their own
+func (c *Checker) Run(servers []*balancer.Server, interval time.Duration, ctx context.Context) {
+	log.Printf("Starting health checks with %v interval", interval)
+
+	c.setDefaultInterval(interval)
+	c.sync(ctx, servers)
+	<-ctx.Done()
+
+	c.stopAll()
+	log.Println("Stopping health checks")
+}
+
+// Watch probes the servers of the balancer like Run, starting and stopping
+// probe loops as servers are added to and removed from the pool
+func (c *Checker) Watch(lb *balancer.Balancer, interval time.Duration, ctx context.Context) {
+	log.Printf("Starting health checks with %v interval", interval)
+
+	c.setDefaultInterval(interval)
+	for {
+		changed := lb.PoolChanged()
+		c.sync(ctx, lb.GetServers())
+
+		select {
+		case <-ctx.Done():
+			c.stopAll()
+			log.Println("Stopping health checks")
+			return
+		case <-changed:
+		}
+	}
+}
+
+// starting loops for new servers and stopping the loops of servers that are gone
+func (c *Checker) sync(ctx context.Context, servers []*balancer.Server) {
+	c.loopMutex.Lock()
+	defer c.loopMutex.Unlock()
+
+	current := make(map[*balancer.Server]bool, len(servers))
+	for _, server := range servers {
+		current[server] = true
+		if _, ok := c.loops[server]; ok {
+			continue
+		}
+		loopCtx, cancel := context.WithCancel(ctx)
+		loop := &probeLoop{cancel: cancel, done: make(chan struct{})}
+		c.loops[server] = loop
+		go c.runLoop(loopCtx, server, loop.done)
+	}
+
+	for server, loop := range c.loops {
+		if !current[server] {
+			loop.cancel()
+			<-loop.done
+			delete(c.loops, server)
+			c.closeGRPCClient(server)
+			log.Printf("Stopped health checks for server %s", server.Address)
+		}
+	}
+}
+
+// stopping every probe loop and waiting for them to return
+func (c *Checker) stopAll() {
+	c.loopMutex.Lock()
+	defer c.loopMutex.Unlock()
+
+	for server, loop := range c.loops {
+		loop.cancel()
+		<-loop.done
+		delete(c.loops, server)
+		c.closeGRPCClient(server)
+	}
+}
+
+// probing the server until the context is cancelled, the first probe runs at
+// once and so does the first probe after the checks are reconfigured
+func (c *Checker) runLoop(ctx context.Context, server *balancer.Server, done chan struct{}) {
+	defer close(done)
+
+	for {
+		configured := c.configChanged()
+		c.checkServerContext(ctx, server)
+
+		timer := time.NewTimer(c.nextProbe(server, c.checkFor(server), c.defaultInterval()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-configured:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// time until the next probe of the server, doubling with each failed probe while
+// the server is unhealthy up to the max backoff, and moved at random by the jitter
+func (c *Checker) nextProbe(server *balancer.Server, check CheckConfig, interval time.Duration) time.Duration {
+	if check.Interval > 0 {
+		interval = check.Interval
+	}
+
+	delay := interval
+	if streak := server.GetHealthStreak(); streak < -1 && !server.IsServerHealthy() {
+		limit := max(check.MaxBackoff, interval)
+		for i := 1; i < -streak && delay < limit; i++ {
+			delay *= 2
+		}
+		delay = min(delay, limit)
+	}
+
+	if c.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * c.Jitter * float64(delay))
+	}
+	return delay
+}
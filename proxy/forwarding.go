@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// settings for the X-Forwarded-*, Forwarded and Via headers
+type ForwardingConfig struct {
+	TrustedProxies []*net.IPNet //clients whose inbound forwarding headers are kept and appended to
+	EmitForwarded  bool         //also send the RFC 7239 Forwarded header
+	ViaPseudonym   string       //name of this hop in the Via header, empty leaves Via untouched
+}
+
+// parsing trusted proxies given as CIDRs or single IPs
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %s", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %s: %v", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// checking if the client address belongs to a trusted proxy
+func (fc ForwardingConfig) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range fc.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// setting the forwarding headers on the outgoing request, inbound values are
+// only kept when the client is a trusted proxy, otherwise they are overwritten
+func (fc ForwardingConfig) setHeaders(proxyReq, r *http.Request) {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	trusted := fc.isTrusted(net.ParseIP(clientIP))
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	if !trusted {
+		proxyReq.Header.Del("X-Forwarded-For")
+		proxyReq.Header.Del("X-Forwarded-Proto")
+		proxyReq.Header.Del("X-Forwarded-Host")
+		proxyReq.Header.Del("Forwarded")
+	}
+
+	if prior := proxyReq.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		proxyReq.Header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+clientIP)
+	} else {
+		proxyReq.Header.Set("X-Forwarded-For", clientIP)
+	}
+	if proxyReq.Header.Get("X-Forwarded-Proto") == "" {
+		proxyReq.Header.Set("X-Forwarded-Proto", proto)
+	}
+	if proxyReq.Header.Get("X-Forwarded-Host") == "" {
+		proxyReq.Header.Set("X-Forwarded-Host", r.Host)
+	}
+
+	if fc.EmitForwarded {
+		element := fmt.Sprintf("for=%s;host=%q;proto=%s", forwardedNode(clientIP), r.Host, proto)
+		if prior := proxyReq.Header.Values("Forwarded"); len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		proxyReq.Header.Set("Forwarded", element)
+	}
+}
+
+// formatting a client address as a Forwarded node, ipv6 addresses are bracketed and quoted
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// pseudonym used in the Via header when none is configured
+const DefaultViaPseudonym = "go-load-balancer"
+
+// hop-by-hop headers from RFC 9110 section 7.6.1, they describe a single
+// connection and must not be forwarded to the next hop
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection", //non standard but still sent by some clients
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removing hop-by-hop headers, including the ones listed in Connection
+func removeHopByHopHeaders(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, field := range strings.Split(value, ",") {
+			if field = textproto.TrimString(field); field != "" {
+				h.Del(field)
+			}
+		}
+	}
+	for _, header := range hopHeaders {
+		h.Del(header)
+	}
+}
+
+// checking if the client asked for trailers, which has to survive hop-by-hop removal for gRPC
+func acceptsTrailers(h http.Header) bool {
+	for _, value := range h.Values("Te") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(textproto.TrimString(field), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appending this hop to the Via header
+func appendVia(h http.Header, protoMajor, protoMinor int, pseudonym string) {
+	if pseudonym == "" {
+		return
+	}
+
+	version := fmt.Sprintf("%d.%d", protoMajor, protoMinor)
+	if protoMajor >= 2 {
+		version = fmt.Sprintf("%d", protoMajor)
+	}
+
+	via := version + " " + pseudonym
+	if prior := h.Values("Via"); len(prior) > 0 {
+		via = strings.Join(prior, ", ") + ", " + via
+	}
+	h.Set("Via", via)
+}
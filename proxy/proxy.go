@@ -0,0 +1,307 @@
+// Package proxy forwards http requests to the servers selected by a balancer.
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// Proxy is an http.Handler that forwards every request to the server
+// selected by its Balancer
+type Proxy struct {
+	Balancer   *balancer.Balancer
+	Transport  TransportConfig //applies to backend transports created after it is set
+	Forwarding ForwardingConfig
+	Tunnel     TunnelConfig
+	Retry      RetryPolicy
+	DebugLog   *log.Logger //logs every forwarded request when set
+
+	transports transportPool
+	retries    retryBudget
+}
+
+// New creates a proxy for the balancer with the default transport settings,
+// it can be mounted in any mux
+func New(lb *balancer.Balancer) *Proxy {
+	return NewWithTransport(lb, DefaultTransportConfig())
+}
+
+// NewWithTransport creates a proxy for the balancer with tuned backend transports
+func NewWithTransport(lb *balancer.Balancer, cfg TransportConfig) *Proxy {
+	return &Proxy{
+		Balancer:   lb,
+		Transport:  cfg,
+		Forwarding: ForwardingConfig{ViaPseudonym: DefaultViaPseudonym},
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// closing idle backend connections
+func (p *Proxy) CloseIdleConnections() {
+	p.transports.closeIdleConnections()
+}
+
+// Close stops watching the pool for removed servers and closes idle backend
+// connections, for use on shutdown
+func (p *Proxy) Close() {
+	p.transports.close()
+}
+
+// mux serving the proxy on / and the status endpoint on /status
+func (p *Proxy) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", p)
+	mux.HandleFunc("/status", p.HandleStatus)
+	return mux
+}
+
+// HTTP handler for load balancing
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lb := p.Balancer
+	//the request counts as in flight from the moment the server is picked, so draining waits for it
+	server := lb.AcquireServer(r, nil)
+	if server == nil {
+		http.Error(w, "No healthy servers available", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, retryable := p.requestBody(r)
+	p.retries.deposit(p.Retry.BudgetRatio)
+
+	//retrying idempotent requests on a different server when the backend cannot be reached
+	tried := make(map[*balancer.Server]bool)
+	for attempt := 0; ; attempt++ {
+		tried[server] = true
+		err := p.forward(w, r, server, body())
+		if err == nil {
+			return
+		}
+		log.Printf("Error: failed to forward request to %s: %v", server.Address, err)
+
+		if !retryable || attempt >= p.Retry.Attempts || r.Context().Err() != nil {
+			break
+		}
+		next := p.nextRetryServer(r, tried)
+		if next == nil {
+			break
+		}
+		if !p.retries.withdraw(p.Retry.BudgetRatio) {
+			log.Printf("Warning: retry budget exhausted, not retrying request on %s", next.Address)
+			lb.ReleaseServer(next)
+			break
+		}
+		log.Printf("Retrying request on %s", next.Address)
+		server = next
+	}
+	http.Error(w, "failed to forward request", http.StatusBadGateway)
+}
+
+// forwarding the request to one acquired server, an error is returned without writing
+// anything when the backend could not be reached so the request can be retried
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, server *balancer.Server, body io.ReadCloser) error {
+	lb := p.Balancer
+
+	//the request was counted on the server when it was acquired
+	defer server.DecrementConnectionCount()
+
+	//creating a proxy request, keeping the full path and query
+	serverURL := server.URL
+	if serverURL == nil {
+		parsed, err := url.Parse(server.Address)
+		if err != nil {
+			http.Error(w, "failed to create proxy request", http.StatusInternalServerError)
+			return nil
+		}
+		serverURL = parsed
+	}
+
+	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL(serverURL, r.URL).String(), body)
+	if err != nil {
+		http.Error(w, "failed to create proxy request", http.StatusInternalServerError)
+		return nil
+	}
+	proxyReq.ContentLength = r.ContentLength
+	proxyReq.Trailer = r.Trailer
+
+	//copying end-to-end headers
+	for header, values := range r.Header {
+		for _, value := range values {
+			proxyReq.Header.Add(header, value)
+		}
+	}
+	removeHopByHopHeaders(proxyReq.Header)
+	if acceptsTrailers(r.Header) {
+		proxyReq.Header.Set("Te", "trailers")
+	}
+
+	//websockets and other upgrades keep their upgrade headers
+	upgrade := upgradeType(r.Header)
+	if upgrade != "" {
+		proxyReq.Header.Set("Connection", "Upgrade")
+		proxyReq.Header.Set("Upgrade", upgrade)
+	}
+	if p.Transport.PreserveHost {
+		proxyReq.Host = r.Host
+	}
+	p.Forwarding.setHeaders(proxyReq, r)
+	appendVia(proxyReq.Header, r.ProtoMajor, r.ProtoMinor, p.Forwarding.ViaPseudonym)
+
+	//making request on the backend's long lived transport, redirects are passed back to the client
+	transport := p.transports.get(p.Balancer, server, p.Transport)
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(proxyReq)
+	if err != nil {
+		//requests cancelled by the client say nothing about the backend
+		if r.Context().Err() == nil {
+			lb.RecordResult(server, true)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	//recording time to response headers for latency aware balancing,
+	//and the result for outlier detection
+	server.RecordLatency(time.Since(start), lb.EWMAHalfLife)
+	lb.RecordResult(server, resp.StatusCode >= http.StatusInternalServerError)
+
+	//the connection count stays raised for the life of the tunnel
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		p.handleUpgradeResponse(w, resp, upgrade, server)
+		return nil
+	}
+
+	//copying end-to-end respose headers
+	removeHopByHopHeaders(resp.Header)
+	appendVia(resp.Header, resp.ProtoMajor, resp.ProtoMinor, p.Forwarding.ViaPseudonym)
+	for header, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(header, value)
+		}
+	}
+
+	//announcing the trailers the backend declared, Trailer itself is hop-by-hop
+	announcedTrailers := len(resp.Trailer)
+	if announcedTrailers > 0 {
+		trailerKeys := make([]string, 0, announcedTrailers)
+		for header := range resp.Trailer {
+			trailerKeys = append(trailerKeys, header)
+		}
+		w.Header().Add("Trailer", strings.Join(trailerKeys, ", "))
+	}
+
+	//copying status code
+	w.WriteHeader(resp.StatusCode)
+
+	//streaming the response body, flushing as data arrives for event streams
+	err = copyResponse(w, resp.Body, p.flushInterval(resp))
+	if err != nil {
+		log.Printf("Error: copying the response body: %v", err)
+	}
+
+	//closing the body fills in the trailer values
+	resp.Body.Close()
+	if len(resp.Trailer) > 0 {
+		//forcing a chunked response so the trailers can be sent
+		http.NewResponseController(w).Flush()
+	}
+	if len(resp.Trailer) == announcedTrailers {
+		for header, values := range resp.Trailer {
+			for _, value := range values {
+				w.Header().Add(header, value)
+			}
+		}
+	} else {
+		//trailers that were not announced up front need the trailer prefix
+		for header, values := range resp.Trailer {
+			for _, value := range values {
+				w.Header().Add(http.TrailerPrefix+header, value)
+			}
+		}
+	}
+
+	if p.DebugLog != nil {
+		p.DebugLog.Printf("request forwarded to %s, status: %d", server.Address, resp.StatusCode)
+	}
+	return nil
+}
+
+// status endpoint response, numbers and flags are encoded as strings
+type status struct {
+	Status    string         `json:"status"`
+	Algorithm string         `json:"algorithm"`
+	Retries   uint64         `json:"retries,string"`
+	Exhausted uint64         `json:"retries_budget_exhausted,string"`
+	Servers   []serverStatus `json:"servers"`
+}
+
+// status of one server
+type serverStatus struct {
+	Address              string `json:"address"`
+	Healthy              bool   `json:"healthy,string"`
+	Ejected              bool   `json:"ejected,string"`
+	Breaker              string `json:"breaker"`
+	Connections          int    `json:"connections,string"`
+	Weight               int    `json:"weight,string"`
+	LatencyEWMAMs        string `json:"latency_ewma_ms"`
+	SlowStartPercent     string `json:"slow_start_percent"`
+	Draining             bool   `json:"draining,string"`
+	LastTransition       string `json:"last_transition"`
+	LastTransitionReason string `json:"last_transition_reason"`
+	CertExpiry           string `json:"cert_expiry"`
+}
+
+// handler for status endpoint
+func (p *Proxy) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	lb := p.Balancer
+
+	retries, exhausted := p.retries.counts()
+	response := status{
+		Status:    "healthy",
+		Algorithm: lb.GetAlgorithm(),
+		Retries:   retries,
+		Exhausted: exhausted,
+		Servers:   []serverStatus{},
+	}
+
+	slowStart := lb.GetSlowStart()
+	for _, server := range lb.GetServers() {
+		latencyMs := float64(server.GetLatencyEWMA(lb.EWMAHalfLife)) / float64(time.Millisecond)
+		transitioned, reason := server.GetLastTransition()
+		lastTransition := ""
+		if !transitioned.IsZero() {
+			lastTransition = transitioned.Format(time.RFC3339)
+		}
+		certExpiry := ""
+		if expiry := server.GetCertExpiry(); !expiry.IsZero() {
+			certExpiry = expiry.Format(time.RFC3339)
+		}
+		response.Servers = append(response.Servers, serverStatus{
+			Address:              server.Address,
+			Healthy:              server.IsServerHealthy(),
+			Ejected:              server.IsServerEjected(),
+			Breaker:              server.GetBreakerState().String(),
+			Connections:          server.GetConnectionCount(),
+			Weight:               server.GetWeight(),
+			LatencyEWMAMs:        fmt.Sprintf("%.2f", latencyMs),
+			SlowStartPercent:     fmt.Sprintf("%.0f", server.GetSlowStartRamp(slowStart)*100),
+			Draining:             server.IsDraining(),
+			LastTransition:       lastTransition,
+			LastTransitionReason: reason,
+			CertExpiry:           certExpiry,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error: writing the status: %v", err)
+	}
+}
@@ -0,0 +1,1333 @@
+package proxy_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+	"github.com/SusheelSathyaraj/go-load-balancer/config"
+	"github.com/SusheelSathyaraj/go-load-balancer/health"
+	"github.com/SusheelSathyaraj/go-load-balancer/proxy"
+)
+
+// integration tests
+func TestFullIntegration(t *testing.T) {
+	//create backend servers
+	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("healthy"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Response from Server1"))
+	}))
+	defer server1.Close()
+
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("healthy"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Response from Server2"))
+	}))
+	defer server2.Close()
+
+	//create config
+	configContent := fmt.Sprintf(`servers:
+	- address: "%s"
+	- address: "%s"
+	health_check_interval: 1
+	load_balancing_algorithm: "round-robin"`, server1.URL, server2.URL)
+
+	tmpFile := "/tmp/integration_config.yaml"
+	err := os.WriteFile(tmpFile, []byte(configContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config, %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	//load config
+	config, err := config.Load(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to load config file, %v", err)
+	}
+
+	//initialise servers
+	servers := make([]*balancer.Server, len(config.Servers))
+	for i, server := range config.Servers {
+		serverURL, _ := url.Parse(server.Address)
+		servers[i] = &balancer.Server{
+			Address:   server.Address,
+			IsHealthy: false,
+			URL:       serverURL,
+		}
+	}
+
+	//create load balancer
+	lb := balancer.NewLoadBalancer(servers, config.LoadBalancingAlgo)
+
+	//run initial health check
+	health.PerformSingleHealthCheck(servers)
+
+	//verify servers are healthy
+	if !servers[0].IsHealthy || !servers[1].IsHealthy {
+		t.Fatal("Expected both servers to be healthy after health check")
+	}
+
+	//create load balancer HTTP server
+	mux := http.NewServeMux()
+	mux.Handle("/", proxy.New(lb))
+	mux.HandleFunc("/status", proxy.New(lb).HandleStatus)
+
+	lbServer := httptest.NewServer(mux)
+	defer lbServer.Close()
+
+	//test load balancing
+	responses := make([]string, 4)
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(lbServer.URL + "/")
+		if err != nil {
+			t.Fatalf("Request %d failed, %v", i, err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read the response body, %v", err)
+		}
+		resp.Body.Close()
+
+		responses[i] = string(body)
+	}
+
+	//verify round robin distribution
+	server1Count := 0
+	server2Count := 0
+
+	for _, response := range responses {
+		if strings.Contains(response, "server 1") {
+			server1Count++
+		} else if strings.Contains(response, "server 2") {
+			server2Count++
+		}
+	}
+
+	if server1Count != 2 || server2Count != 2 {
+		t.Errorf("Expected 2 requests to each server, got server1: %d and server2: %d", server1Count, server2Count)
+	}
+
+	//test status endpoints
+	resp, err := http.Get(lbServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("Status request failed, %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the response body, %v", err)
+	}
+
+	statusResponse := string(body)
+	if !strings.Contains(statusResponse, "healthy") {
+		t.Errorf("Status response should contain 'healthy'")
+	}
+
+	if !strings.Contains(statusResponse, "round-robin") {
+		t.Errorf("Status response should contain algorithm name")
+	}
+}
+
+// creates a backend that echoes the request uri and host it received
+func createEchoBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "uri=%s host=%s", r.URL.RequestURI(), r.Host)
+	}))
+}
+
+// creates a load balancer with a single healthy backend behind a proxy
+func createProxy(backend *httptest.Server, cfg proxy.TransportConfig) (*proxy.Proxy, *httptest.Server) {
+	server, _ := balancer.NewServer(backend.URL)
+	server.SetHealthy(true)
+
+	p := proxy.NewWithTransport(balancer.NewLoadBalancer([]*balancer.Server{server}, "round-robin"), cfg)
+	return p, httptest.NewServer(p)
+}
+
+func TestProxyPreservesPathAndQuery(t *testing.T) {
+	backend := createEchoBackend()
+	defer backend.Close()
+
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	tests := []string{
+		"/",
+		"/api/users?id=42",
+		"/api/users?id=42&sort=name&sort=age",
+		"/files/a%2Fb/c?q=hello%20world",
+	}
+
+	for _, uri := range tests {
+		resp, err := http.Get(lbServer.URL + uri)
+		if err != nil {
+			t.Fatalf("Request %s failed, %v", uri, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(string(body), "uri="+uri+" ") {
+			t.Errorf("Expected backend to receive %s, got %s", uri, string(body))
+		}
+	}
+}
+
+func TestProxyClosesConnectionsOfRemovedServers(t *testing.T) {
+	var closed atomic.Int64
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	backend.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	p, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+	defer p.Close()
+
+	resp, err := http.Get(lbServer.URL + "/")
+	if err != nil {
+		t.Fatalf("Request failed, %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if closed.Load() != 0 {
+		t.Fatalf("Expected the backend connection to be kept alive")
+	}
+
+	//the idle keep-alive connection is closed once the server leaves the pool
+	p.Balancer.RemoveServer(backend.URL)
+	deadline := time.Now().Add(2 * time.Second)
+	for closed.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the idle connection of the removed server to be closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProxyHostHandling(t *testing.T) {
+	backend := createEchoBackend()
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+
+	//by default the backend sees its own host
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, lbServer.URL+"/", nil)
+	req.Host = "example.com"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed, %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !strings.Contains(string(body), "host="+backendURL.Host) {
+		t.Errorf("Expected backend host %s, got %s", backendURL.Host, string(body))
+	}
+
+	//with preserve host the client's host is forwarded
+	cfg := proxy.DefaultTransportConfig()
+	cfg.PreserveHost = true
+	_, preservingServer := createProxy(backend, cfg)
+	defer preservingServer.Close()
+
+	req, _ = http.NewRequest(http.MethodGet, preservingServer.URL+"/", nil)
+	req.Host = "example.com"
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed, %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !strings.Contains(string(body), "host=example.com") {
+		t.Errorf("Expected client host example.com, got %s", string(body))
+	}
+}
+
+func TestProxyReusesBackendConnections(t *testing.T) {
+	var mutex sync.Mutex
+	newConnections := 0
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	backend.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			newConnections++
+			mutex.Unlock()
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	for i := 0; i < 20; i++ {
+		resp, err := http.Get(lbServer.URL + "/")
+		if err != nil {
+			t.Fatalf("Request %d failed, %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if newConnections != 1 {
+		t.Errorf("Expected sequential requests to reuse 1 backend connection, got %d connections", newConnections)
+	}
+}
+
+func TestProxyDoesNotFollowRedirects(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer backend.Close()
+
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(lbServer.URL + "/")
+	if err != nil {
+		t.Fatalf("Request failed, %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/elsewhere" {
+		t.Errorf("Expected the redirect to be passed to the client, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+// creates a backend that echoes the forwarding headers it received
+func createHeaderEchoBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, header := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "Forwarded"} {
+			fmt.Fprintf(w, "%s: %s\n", header, r.Header.Get(header))
+		}
+	}))
+}
+
+// sends a request through the proxy with spoofed forwarding headers and returns what the backend saw
+func getForwardingHeaders(t *testing.T, lbURL string) string {
+	req, _ := http.NewRequest(http.MethodGet, lbURL+"/", nil)
+	req.Host = "shop.example.com"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "spoofed.example.com")
+	req.Header.Set("Forwarded", "for=203.0.113.7")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed, %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestProxyForwardingHeaders(t *testing.T) {
+	backend := createHeaderEchoBackend()
+	defer backend.Close()
+
+	t.Run("Untrusted client", func(t *testing.T) {
+		p, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+		defer lbServer.Close()
+		p.Forwarding.EmitForwarded = true
+
+		headers := getForwardingHeaders(t, lbServer.URL)
+
+		//spoofed values are replaced with what the balancer observed
+		expected := []string{
+			"X-Forwarded-For: 127.0.0.1\n",
+			"X-Forwarded-Proto: http\n",
+			"X-Forwarded-Host: shop.example.com\n",
+			"Forwarded: for=127.0.0.1;host=\"shop.example.com\";proto=http\n",
+		}
+		for _, header := range expected {
+			if !strings.Contains(headers, header) {
+				t.Errorf("Expected backend to receive %q, got:\n%s", header, headers)
+			}
+		}
+	})
+
+	t.Run("Trusted proxy", func(t *testing.T) {
+		p, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+		defer lbServer.Close()
+
+		trusted, err := proxy.ParseTrustedProxies([]string{"127.0.0.0/8"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		p.Forwarding = proxy.ForwardingConfig{TrustedProxies: trusted, EmitForwarded: true}
+
+		headers := getForwardingHeaders(t, lbServer.URL)
+
+		//inbound values are kept and the client address is appended
+		expected := []string{
+			"X-Forwarded-For: 203.0.113.7, 127.0.0.1\n",
+			"X-Forwarded-Proto: https\n",
+			"X-Forwarded-Host: spoofed.example.com\n",
+			"Forwarded: for=203.0.113.7, for=127.0.0.1;host=\"shop.example.com\";proto=http\n",
+		}
+		for _, header := range expected {
+			if !strings.Contains(headers, header) {
+				t.Errorf("Expected backend to receive %q, got:\n%s", header, headers)
+			}
+		}
+	})
+
+	t.Run("Forwarded header disabled", func(t *testing.T) {
+		_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+		defer lbServer.Close()
+
+		if headers := getForwardingHeaders(t, lbServer.URL); !strings.Contains(headers, "Forwarded: \n") {
+			t.Errorf("Expected no Forwarded header by default, got:\n%s", headers)
+		}
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := proxy.ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32", "::1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(networks) != 4 {
+		t.Errorf("Expected 4 networks, got %d", len(networks))
+	}
+
+	for _, invalid := range []string{"not-an-ip", "10.0.0.0/99"} {
+		if _, err := proxy.ParseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %s", invalid)
+		}
+	}
+}
+
+func TestProxyStripsHopByHopRequestHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	//raw request so the client library does not touch the hop-by-hop headers
+	conn, err := net.Dial("tcp", lbServer.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect, %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\n"+
+		"Host: shop.example.com\r\n"+
+		"Connection: keep-alive, X-Hop-Custom\r\n"+
+		"X-Hop-Custom: secret\r\n"+
+		"Keep-Alive: timeout=5\r\n"+
+		"Proxy-Authorization: Basic Zm9vOmJhcg==\r\n"+
+		"Proxy-Connection: keep-alive\r\n"+
+		"Te: trailers, deflate\r\n"+
+		"Via: 1.0 edge\r\n"+
+		"X-End-To-End: kept\r\n"+
+		"\r\n")
+
+	var header http.Header
+	select {
+	case header = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Backend did not receive the request")
+	}
+
+	for _, hop := range []string{"Connection", "X-Hop-Custom", "Keep-Alive", "Proxy-Authorization", "Proxy-Connection"} {
+		if value := header.Get(hop); value != "" {
+			t.Errorf("Expected %s to be stripped, backend got %q", hop, value)
+		}
+	}
+	if header.Get("X-End-To-End") != "kept" {
+		t.Errorf("Expected end-to-end headers to reach the backend")
+	}
+	if header.Get("Te") != "trailers" {
+		t.Errorf("Expected Te to be reduced to trailers, got %q", header.Get("Te"))
+	}
+	if header.Get("Via") != "1.0 edge, 1.1 go-load-balancer" {
+		t.Errorf("Expected Via to be appended, got %q", header.Get("Via"))
+	}
+}
+
+func TestProxyStripsHopByHopResponseHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "X-Backend-Hop")
+		w.Header().Set("X-Backend-Hop", "internal")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		w.Header().Set("Proxy-Authenticate", "Basic")
+		w.Header().Set("X-End-To-End", "kept")
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	p, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+	p.Forwarding.ViaPseudonym = "edge-lb"
+
+	resp, err := http.Get(lbServer.URL + "/")
+	if err != nil {
+		t.Fatalf("Request failed, %v", err)
+	}
+	resp.Body.Close()
+
+	for _, hop := range []string{"Connection", "X-Backend-Hop", "Keep-Alive", "Proxy-Authenticate"} {
+		if value := resp.Header.Get(hop); value != "" {
+			t.Errorf("Expected %s to be stripped, client got %q", hop, value)
+		}
+	}
+	if resp.Header.Get("X-End-To-End") != "kept" {
+		t.Errorf("Expected end-to-end headers to reach the client")
+	}
+	if resp.Header.Get("Via") != "1.1 edge-lb" {
+		t.Errorf("Expected Via with the configured pseudonym, got %q", resp.Header.Get("Via"))
+	}
+}
+
+// creates a backend that accepts websocket upgrades and echoes every line back
+func createUpgradeBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusBadRequest)
+			return
+		}
+
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+
+		for {
+			line, err := buf.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fmt.Fprint(buf, "echo: "+line)
+			buf.Flush()
+		}
+	}))
+}
+
+// opens an upgraded connection through the load balancer
+func dialUpgrade(t *testing.T, lbURL, protocol string) (net.Conn, *bufio.Reader, *http.Response) {
+	lbAddr, _ := url.Parse(lbURL)
+	conn, err := net.Dial("tcp", lbAddr.Host)
+	if err != nil {
+		t.Fatalf("Failed to connect, %v", err)
+	}
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", lbAddr.Host, protocol)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		conn.Close()
+		t.Fatalf("Failed to read upgrade response, %v", err)
+	}
+	return conn, reader, resp
+}
+
+func TestProxyWebSocketTunnel(t *testing.T) {
+	backend := createUpgradeBackend()
+	defer backend.Close()
+
+	p, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+	server := p.Balancer.Servers[0]
+
+	conn, reader, resp := dialUpgrade(t, lbServer.URL, "websocket")
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101 Switching Protocols, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Upgrade") != "websocket" || !strings.EqualFold(resp.Header.Get("Connection"), "upgrade") {
+		t.Errorf("Expected upgrade headers on the 101 response, got %v", resp.Header)
+	}
+
+	//bytes should flow both ways
+	for _, message := range []string{"hello", "world"} {
+		fmt.Fprintf(conn, "%s\n", message)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read from tunnel, %v", err)
+		}
+		if line != "echo: "+message+"\n" {
+			t.Errorf("Expected echo of %s, got %q", message, line)
+		}
+	}
+
+	//the long lived socket should count as an active connection
+	if server.GetConnectionCount() != 1 {
+		t.Errorf("Expected 1 active connection during the tunnel, got %d", server.GetConnectionCount())
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for server.GetConnectionCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.GetConnectionCount() != 0 {
+		t.Errorf("Expected the connection count to drop after the tunnel closed, got %d", server.GetConnectionCount())
+	}
+}
+
+func TestProxyUpgradeRejected(t *testing.T) {
+	backend := createUpgradeBackend()
+	defer backend.Close()
+
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	//the backend refuses, its response is relayed as a normal response
+	conn, _, resp := dialUpgrade(t, lbServer.URL, "h2c")
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected the backend's 400 to be relayed, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxyTunnelLimits(t *testing.T) {
+	backend := createUpgradeBackend()
+	defer backend.Close()
+
+	tests := []struct {
+		name   string
+		tunnel proxy.TunnelConfig
+	}{
+		{"Idle timeout", proxy.TunnelConfig{IdleTimeout: 200 * time.Millisecond}},
+		{"Max duration", proxy.TunnelConfig{MaxDuration: 300 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+			defer lbServer.Close()
+			p.Tunnel = tt.tunnel
+
+			conn, reader, resp := dialUpgrade(t, lbServer.URL, "websocket")
+			defer conn.Close()
+			if resp.StatusCode != http.StatusSwitchingProtocols {
+				t.Fatalf("Expected 101 Switching Protocols, got %d", resp.StatusCode)
+			}
+
+			//the tunnel should be closed by the balancer without any action from the client
+			start := time.Now()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if _, err := reader.ReadString('\n'); err == nil {
+				t.Fatalf("Expected the tunnel to be closed")
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Expected the tunnel to close within the limit, took %v", elapsed)
+			}
+		})
+	}
+}
+
+// creates a backend that streams events slowly, each event waits for the test to release it
+func createStreamingBackend(contentType string, release <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for i := 1; i <= 3; i++ {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+			fmt.Fprintf(w, "data: event %d\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+// reading one event from the stream, failing if it does not arrive in time
+func readEvent(t *testing.T, events <-chan string) string {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the event to be flushed to the client")
+		return ""
+	}
+}
+
+func TestProxyStreamsResponses(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentType   string
+		flushInterval time.Duration
+	}{
+		{"Server-Sent Events", "text/event-stream; charset=utf-8", 0},
+		{"Flush every write", "text/plain", -1},
+		{"Flush interval", "text/plain", 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			backend := createStreamingBackend(tt.contentType, release)
+			defer backend.Close()
+
+			cfg := proxy.DefaultTransportConfig()
+			cfg.FlushInterval = tt.flushInterval
+			_, lbServer := createProxy(backend, cfg)
+			defer lbServer.Close()
+
+			//reading in the background so a stalled stream fails the test instead of hanging it
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := make(chan string)
+			go func() {
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, lbServer.URL+"/events", nil)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					close(events)
+					return
+				}
+				defer resp.Body.Close()
+
+				reader := bufio.NewReader(resp.Body)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						close(events)
+						return
+					}
+					if strings.HasPrefix(line, "data: ") {
+						select {
+						case events <- strings.TrimSpace(strings.TrimPrefix(line, "data: ")):
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}()
+
+			//every event should reach the client while the backend is still streaming
+			for i := 1; i <= 3; i++ {
+				release <- struct{}{}
+				if event := readEvent(t, events); event != fmt.Sprintf("event %d", i) {
+					t.Errorf("Expected event %d, got %q", i, event)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyResponseTrailers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "body")
+		w.Header().Set("X-Checksum", "abc123")
+		w.Header().Set(http.TrailerPrefix+"X-Late", "unannounced")
+	}))
+	defer backend.Close()
+
+	_, lbServer := createProxy(backend, proxy.DefaultTransportConfig())
+	defer lbServer.Close()
+
+	resp, err := http.Get(lbServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to make request, %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "body" {
+		t.Errorf("Expected body to be forwarded, got %q", body)
+	}
+	if resp.Trailer.Get("X-Checksum") != "abc123" {
+		t.Errorf("Expected announced trailer abc123, got %q", resp.Trailer.Get("X-Checksum"))
+	}
+	if resp.Trailer.Get("X-Late") != "unannounced" {
+		t.Errorf("Expected unannounced trailer, got %q", resp.Trailer.Get("X-Late"))
+	}
+}
+
+// gets the address of a backend that closes every connection without a response,
+// the listener stays open until the test ends so its port cannot be reused
+func createDeadBackend(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen, %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return "http://" + listener.Addr().String()
+}
+
+// creates a round robin proxy over the addresses with the retry policy
+func createRetryProxy(policy proxy.RetryPolicy, addresses ...string) (*proxy.Proxy, *httptest.Server) {
+	var servers []*balancer.Server
+	for _, address := range addresses {
+		server, _ := balancer.NewServer(address)
+		server.SetHealthy(true)
+		servers = append(servers, server)
+	}
+
+	p := proxy.New(balancer.NewLoadBalancer(servers, "round-robin"))
+	p.Retry = policy
+	return p, httptest.NewServer(p.Handler())
+}
+
+// reading the retry counters from the status endpoint
+func getRetryCounts(t *testing.T, lbURL string) (string, string) {
+	resp, err := http.Get(lbURL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status, %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Retries   string `json:"retries"`
+		Exhausted string `json:"retries_budget_exhausted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status, %v", err)
+	}
+	return status.Retries, status.Exhausted
+}
+
+func TestProxyRetriesOnAnotherServer(t *testing.T) {
+	backend := createEchoBackend()
+	defer backend.Close()
+
+	policy := proxy.DefaultRetryPolicy()
+	policy.Attempts = 2
+	_, lbServer := createRetryProxy(policy, createDeadBackend(t), backend.URL)
+	defer lbServer.Close()
+
+	//round robin sends the first request to the dead backend
+	resp, err := http.Get(lbServer.URL + "/retry")
+	if err != nil {
+		t.Fatalf("Failed to make request, %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the request to be retried on the healthy backend, got %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(string(body), "uri=/retry ") {
+		t.Errorf("Expected the response from the healthy backend, got %q", body)
+	}
+	if retries, _ := getRetryCounts(t, lbServer.URL); retries != "1" {
+		t.Errorf("Expected 1 retry in status, got %s", retries)
+	}
+}
+
+func TestProxyRetryEligibility(t *testing.T) {
+	var received []string
+	var mutex sync.Mutex
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		received = append(received, string(body))
+		mutex.Unlock()
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		bufferBodies bool
+		maxBodyBytes int64
+		expected     int
+	}{
+		{"Idempotent without body", http.MethodDelete, "", false, 1024, http.StatusOK},
+		{"Non-idempotent method", http.MethodPost, "payload", true, 1024, http.StatusBadGateway},
+		{"Body without buffering", http.MethodPut, "payload", false, 1024, http.StatusBadGateway},
+		{"Buffered body", http.MethodPut, "payload", true, 1024, http.StatusOK},
+		{"Body over the limit", http.MethodPut, "payload", true, 4, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := proxy.DefaultRetryPolicy()
+			policy.Attempts = 1
+			policy.BufferBodies = tt.bufferBodies
+			policy.MaxBodyBytes = tt.maxBodyBytes
+			_, lbServer := createRetryProxy(policy, createDeadBackend(t), backend.URL)
+			defer lbServer.Close()
+
+			mutex.Lock()
+			received = nil
+			mutex.Unlock()
+
+			req, _ := http.NewRequest(tt.method, lbServer.URL, strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to make request, %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+
+			//a retried body should reach the backend intact
+			mutex.Lock()
+			defer mutex.Unlock()
+			if tt.expected == http.StatusOK && (len(received) != 1 || received[0] != tt.body) {
+				t.Errorf("Expected the backend to receive %q once, got %q", tt.body, received)
+			}
+		})
+	}
+}
+
+func TestProxyRetryBudget(t *testing.T) {
+	policy := proxy.DefaultRetryPolicy()
+	policy.Attempts = 1
+	policy.BudgetRatio = 0.1
+	p, lbServer := createRetryProxy(policy, createDeadBackend(t), createDeadBackend(t))
+	defer lbServer.Close()
+
+	//keeping both dead servers in rotation so only the budget limits retries
+	p.Balancer.ConfigureOutlierDetection(balancer.OutlierDetection{})
+	p.Balancer.ConfigureCircuitBreaker(balancer.CircuitBreaker{})
+
+	//during an outage retries are limited by the budget instead of doubling the load
+	requests := 50
+	for i := 0; i < requests; i++ {
+		resp, err := http.Get(lbServer.URL)
+		if err != nil {
+			t.Fatalf("Failed to make request, %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("Expected 502 with every backend down, got %d", resp.StatusCode)
+		}
+	}
+
+	retries, exhausted := getRetryCounts(t, lbServer.URL)
+	var retryCount, exhaustedCount int
+	fmt.Sscanf(retries, "%d", &retryCount)
+	fmt.Sscanf(exhausted, "%d", &exhaustedCount)
+
+	if retryCount >= requests/2 {
+		t.Errorf("Expected the budget to limit retries, got %d retries for %d requests", retryCount, requests)
+	}
+	if exhaustedCount == 0 || retryCount+exhaustedCount != requests {
+		t.Errorf("Expected every request to retry or hit the budget, got %d retries and %d exhausted", retryCount, exhaustedCount)
+	}
+}
+
+func TestProxyRetryBudgetRatio(t *testing.T) {
+	requests := 30
+	tests := []struct {
+		name     string
+		ratio    float64
+		expected int
+	}{
+		{"Reserve only", 0, 10},
+		{"Off", -1, requests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := proxy.DefaultRetryPolicy()
+			policy.Attempts = 1
+			policy.BudgetRatio = tt.ratio
+			p, lbServer := createRetryProxy(policy, createDeadBackend(t), createDeadBackend(t))
+			defer lbServer.Close()
+			p.Balancer.ConfigureOutlierDetection(balancer.OutlierDetection{})
+			p.Balancer.ConfigureCircuitBreaker(balancer.CircuitBreaker{})
+
+			for i := 0; i < requests; i++ {
+				resp, err := http.Get(lbServer.URL)
+				if err != nil {
+					t.Fatalf("Failed to make request, %v", err)
+				}
+				resp.Body.Close()
+			}
+
+			retries, _ := getRetryCounts(t, lbServer.URL)
+			var retryCount int
+			fmt.Sscanf(retries, "%d", &retryCount)
+			if retryCount != tt.expected {
+				t.Errorf("Expected %d retries for %d requests, got %d", tt.expected, requests, retryCount)
+			}
+		})
+	}
+}
+
+func TestProxyEjectsFailingServer(t *testing.T) {
+	backend := createEchoBackend()
+	defer backend.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	p, lbServer := createRetryProxy(proxy.DefaultRetryPolicy(), failing.URL, backend.URL)
+	defer lbServer.Close()
+	failingServer := p.Balancer.Servers[0]
+
+	//round robin alternates until the failing server hits the consecutive error limit
+	for i := 0; i < 2*p.Balancer.Outlier.ConsecutiveErrors; i++ {
+		resp, err := http.Get(lbServer.URL)
+		if err != nil {
+			t.Fatalf("Failed to make request, %v", err)
+		}
+		resp.Body.Close()
+	}
+	if !failingServer.IsServerEjected() {
+		t.Fatalf("Expected the failing server to be ejected from live traffic")
+	}
+
+	for i := 0; i < 10; i++ {
+		resp, err := http.Get(lbServer.URL)
+		if err != nil {
+			t.Fatalf("Failed to make request, %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected requests to avoid the ejected server, got %d", resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(lbServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status, %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	expected := fmt.Sprintf(`{"address":"%s","healthy":"true","ejected":"true"`, failing.URL)
+	if !strings.Contains(string(body), expected) {
+		t.Errorf("Expected status to show the ejection, got %s", body)
+	}
+}
+
+func TestProxyCircuitBreaker(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	p, lbServer := createRetryProxy(proxy.DefaultRetryPolicy(), failing.URL)
+	defer lbServer.Close()
+
+	for i := 0; i < p.Balancer.Breaker.FailureThreshold; i++ {
+		resp, err := http.Get(lbServer.URL)
+		if err != nil {
+			t.Fatalf("Failed to make request, %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	//the only server is blocked by its breaker
+	resp, err := http.Get(lbServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to make request, %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the breaker open, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(lbServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status, %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"breaker":"open"`) {
+		t.Errorf("Expected status to show the open breaker, got %s", body)
+	}
+}
+
+func TestStatusShowsSlowStart(t *testing.T) {
+	backend := createEchoBackend()
+	defer backend.Close()
+
+	p, lbServer := createRetryProxy(proxy.DefaultRetryPolicy(), backend.URL)
+	defer lbServer.Close()
+	p.Balancer.ConfigureSlowStart(balancer.SlowStart{Window: time.Hour, Mode: balancer.SlowStartLinear, MinFraction: 0.1})
+	server := p.Balancer.GetServers()[0]
+	server.SetHealthy(false)
+	server.SetHealthy(true)
+
+	resp, err := http.Get(lbServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status, %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Servers []struct {
+			SlowStartPercent string `json:"slow_start_percent"`
+		} `json:"servers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status, %v", err)
+	}
+	if len(status.Servers) != 1 || status.Servers[0].SlowStartPercent != "10" {
+		t.Errorf("Expected the server that just recovered at 10%%, got %+v", status.Servers)
+	}
+}
+
+func TestProxyDebugLog(t *testing.T) {
+	backend := createEchoBackend()
+	defer backend.Close()
+
+	p, lbServer := createRetryProxy(proxy.DefaultRetryPolicy(), backend.URL)
+
+	//forwarded requests are only logged to the debug logger when one is set
+	var logged strings.Builder
+	p.DebugLog = log.New(&logged, "Debug: ", 0)
+
+	resp, err := http.Get(lbServer.URL + "/item")
+	if err != nil {
+		t.Fatalf("Failed to make request, %v", err)
+	}
+	resp.Body.Close()
+	//closing waits for the handler to finish logging
+	lbServer.Close()
+
+	expected := fmt.Sprintf("Debug: request forwarded to %s, status: 200", backend.URL)
+	if !strings.Contains(logged.String(), expected) {
+		t.Errorf("Expected %q in the debug log, got %q", expected, logged.String())
+	}
+}
+
+func TestStatusEscapesValues(t *testing.T) {
+	address := `http://localhost:8081/a"b\c`
+	p, lbServer := createRetryProxy(proxy.DefaultRetryPolicy(), address)
+	defer lbServer.Close()
+	server := p.Balancer.GetServers()[0]
+	server.RecordHealthCheck(false, `body "degraded" did not match`, 1, 1)
+
+	resp, err := http.Get(lbServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status, %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Servers []struct {
+			Address string `json:"address"`
+			Healthy string `json:"healthy"`
+			Reason  string `json:"last_transition_reason"`
+		} `json:"servers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Expected valid json, %v", err)
+	}
+	if len(status.Servers) != 1 || status.Servers[0].Address != address || status.Servers[0].Healthy != "false" {
+		t.Errorf("Expected the address to round trip, got %+v", status.Servers)
+	}
+	if len(status.Servers) == 1 && !strings.HasPrefix(status.Servers[0].Reason, `body "degraded"`) {
+		t.Errorf("Expected the reason with its quotes, got %q", status.Servers[0].Reason)
+	}
+}
+
+func TestProxyDrainsServer(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	other := createEchoBackend()
+	defer other.Close()
+
+	p, lbServer := createRetryProxy(proxy.DefaultRetryPolicy(), slow.URL, other.URL)
+	defer lbServer.Close()
+
+	//a request in flight on the server about to drain
+	inFlight := make(chan int)
+	go func() {
+		resp, err := http.Get(lbServer.URL + "/slow")
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+	<-started
+
+	if err := p.Balancer.DrainServer(slow.URL, 0); err != nil {
+		t.Fatalf("Failed to drain the server, %v", err)
+	}
+
+	resp, err := http.Get(lbServer.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status, %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"draining":"true"`) {
+		t.Errorf("Expected status to show the draining server, got %s", body)
+	}
+
+	//new requests go to the other server
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(lbServer.URL + "/new")
+		if err != nil {
+			t.Fatalf("Failed to make request, %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.HasPrefix(string(body), "uri=/new") {
+			t.Errorf("Expected new requests to avoid the draining server, got %q", body)
+		}
+	}
+
+	//the request in flight completes and the server leaves the pool afterwards
+	close(release)
+	if code := <-inFlight; code != http.StatusOK {
+		t.Errorf("Expected the in-flight request to complete with 200, got %d", code)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Balancer.GetServerCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.Balancer.GetServerCount() != 1 {
+		t.Errorf("Expected the drained server to be removed, got %d servers", p.Balancer.GetServerCount())
+	}
+}
+
+//Benchmark tests
+
+// compares the old forwarding setup, a new client per request on the default
+// transport which keeps only 2 idle connections per backend, with the proxy
+// forwarding over its shared per-backend transport
+func BenchmarkProxyThroughput(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	//forwarding as the proxy did before it had its own transports
+	perRequestClient := func(lb *balancer.Balancer) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			server := lb.GetNextServerForRequest(r)
+			proxyReq, _ := http.NewRequest(r.Method, server.Address+r.URL.Path, r.Body)
+			client := &http.Client{Timeout: 30 * time.Second}
+			resp, err := client.Do(proxyReq)
+			if err != nil {
+				http.Error(w, "Bad Gateway", http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+		})
+	}
+	sharedTransport := func(lb *balancer.Balancer) http.Handler {
+		return proxy.New(lb)
+	}
+
+	for _, bc := range []struct {
+		name    string
+		handler func(*balancer.Balancer) http.Handler
+	}{
+		{"per-request-client", perRequestClient},
+		{"shared-transport", sharedTransport},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			server, _ := balancer.NewServer(backend.URL)
+			server.SetHealthy(true)
+			lbServer := httptest.NewServer(bc.handler(balancer.NewLoadBalancer([]*balancer.Server{server}, "round-robin")))
+			defer lbServer.Close()
+
+			client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 100}}
+			defer client.CloseIdleConnections()
+
+			//more concurrent requests than the default transport keeps idle connections
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := client.Get(lbServer.URL + "/")
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// retries that can be spent before the budget has been earned by ordinary requests
+const retryBudgetReserve = 10
+
+// settings for retrying failed requests on another backend
+type RetryPolicy struct {
+	Attempts     int     //retries after the first attempt, 0 disables retries
+	BufferBodies bool    //buffer request bodies so requests with bodies can be retried
+	MaxBodyBytes int64   //bodies larger than this are streamed and never retried
+	BudgetRatio  float64 //retries earned per request, 0 earns none beyond the reserve, -1 turns the budget off
+}
+
+// retry settings used when none are configured, retries are off until Attempts is set
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxBodyBytes: 1 << 20,
+		BudgetRatio:  0.2,
+	}
+}
+
+// checking if the method can be sent twice without changing the result, RFC 9110 section 9.2.2
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// getting the body for each attempt, retryable is false when the body can only be sent once
+func (p *Proxy) requestBody(r *http.Request) (body func() io.ReadCloser, retryable bool) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return func() io.ReadCloser { return nil }, p.Retry.Attempts > 0 && isIdempotent(r.Method)
+	}
+
+	streamed := func() io.ReadCloser { return r.Body }
+	if p.Retry.Attempts <= 0 || !isIdempotent(r.Method) || !p.Retry.BufferBodies || r.ContentLength > p.Retry.MaxBodyBytes {
+		return streamed, false
+	}
+
+	//reading one byte past the limit to find bodies of unknown length that are too large
+	buf, err := io.ReadAll(io.LimitReader(r.Body, p.Retry.MaxBodyBytes+1))
+	if err != nil || int64(len(buf)) > p.Retry.MaxBodyBytes {
+		rest := r.Body
+		return func() io.ReadCloser {
+			return readCloser{io.MultiReader(bytes.NewReader(buf), rest), rest}
+		}, false
+	}
+	return func() io.ReadCloser { return io.NopCloser(bytes.NewReader(buf)) }, true
+}
+
+// reader that closes the underlying request body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// acquiring a server that has not been tried yet, tried servers are skipped before
+// they can take a probe slot of a half-open breaker
+func (p *Proxy) nextRetryServer(r *http.Request, tried map[*balancer.Server]bool) *balancer.Server {
+	return p.Balancer.AcquireServer(r, func(server *balancer.Server) bool {
+		return tried[server]
+	})
+}
+
+// retry budget, every request earns a fraction of a retry so retries cannot
+// multiply the load on the backends during an outage
+type retryBudget struct {
+	mutex     sync.Mutex
+	deficit   float64 //retries spent beyond what requests have earned
+	retries   uint64
+	exhausted uint64
+}
+
+// earning budget for a new request
+func (rb *retryBudget) deposit(ratio float64) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	if ratio < 0 {
+		return
+	}
+	rb.deficit -= ratio
+	if rb.deficit < 0 {
+		rb.deficit = 0
+	}
+}
+
+// spending budget on a retry, false when the budget is exhausted
+func (rb *retryBudget) withdraw(ratio float64) bool {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	if ratio >= 0 && rb.deficit+1 > retryBudgetReserve {
+		rb.exhausted++
+		return false
+	}
+	if ratio >= 0 {
+		rb.deficit++
+	}
+	rb.retries++
+	return true
+}
+
+// getting the number of retries made and skipped for lack of budget
+func (rb *retryBudget) counts() (retries, exhausted uint64) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	return rb.retries, rb.exhausted
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// getting how often the response should be flushed, event streams are always flushed
+// immediately, negative means after every write and zero means only when the buffer fills
+func (p *Proxy) flushInterval(resp *http.Response) time.Duration {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return -1
+	}
+	return p.Transport.FlushInterval
+}
+
+// copying the backend body to the client, flushing as configured
+func copyResponse(w http.ResponseWriter, body io.Reader, flushInterval time.Duration) error {
+	if flushInterval == 0 {
+		_, err := io.Copy(w, body)
+		return err
+	}
+
+	fw := &flushWriter{w: w, rc: http.NewResponseController(w), interval: flushInterval}
+	defer fw.stop()
+
+	//sending the headers right away so the client knows the stream has started
+	if err := fw.flush(); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(fw, body)
+	return err
+}
+
+// writer that flushes after every write or at most once per interval
+type flushWriter struct {
+	w        io.Writer
+	rc       *http.ResponseController
+	interval time.Duration
+
+	mutex   sync.Mutex
+	timer   *time.Timer
+	pending bool
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	n, err := fw.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	if fw.interval < 0 {
+		return n, fw.flush()
+	}
+
+	//batching writes until the timer fires
+	if !fw.pending {
+		fw.pending = true
+		if fw.timer == nil {
+			fw.timer = time.AfterFunc(fw.interval, fw.delayedFlush)
+		} else {
+			fw.timer.Reset(fw.interval)
+		}
+	}
+	return n, nil
+}
+
+func (fw *flushWriter) delayedFlush() {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	if !fw.pending {
+		return
+	}
+	fw.flush()
+	fw.pending = false
+}
+
+// flushing the response, writers that cannot flush are written through as is
+func (fw *flushWriter) flush() error {
+	if err := fw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func (fw *flushWriter) stop() {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	fw.pending = false
+	if fw.timer != nil {
+		fw.timer.Stop()
+	}
+}
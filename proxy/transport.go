@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// connection pool and timeout settings for the backend transports
+type TransportConfig struct {
+	MaxIdleConns          int           //idle connections kept across all backends
+	MaxIdleConnsPerHost   int           //idle connections kept per backend
+	MaxConnsPerHost       int           //0 means no limit
+	IdleConnTimeout       time.Duration //how long an idle connection stays in the pool
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration //0 means no limit
+	PreserveHost          bool          //forward the client's Host header instead of the backend's
+	FlushInterval         time.Duration //how often streamed responses are flushed, negative flushes every write
+}
+
+// transport settings used when none are configured
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:          1000,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           5 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+}
+
+// creating a transport for one backend
+func newTransport(cfg TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// long lived transports, one per backend so each keeps its own idle pool,
+// transports of servers that leave the pool are evicted
+type transportPool struct {
+	transports map[string]*http.Transport
+	mutex      sync.Mutex
+	done       chan struct{} //stops the eviction, nil until the first transport is created
+}
+
+// getting the transport for the server, creating it on first use, the first
+// transport starts evicting the transports of servers removed from the balancer
+func (tp *transportPool) get(lb *balancer.Balancer, server *balancer.Server, cfg TransportConfig) *http.Transport {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+
+	if tp.transports == nil {
+		tp.transports = make(map[string]*http.Transport)
+	}
+	if tp.done == nil {
+		tp.done = make(chan struct{})
+		go tp.evictRemoved(lb, tp.done)
+	}
+
+	transport, ok := tp.transports[server.Address]
+	if !ok {
+		transport = newTransport(cfg)
+		tp.transports[server.Address] = transport
+	}
+	return transport
+}
+
+// evicting transports every time the pool changes until done is closed
+func (tp *transportPool) evictRemoved(lb *balancer.Balancer, done chan struct{}) {
+	for {
+		changed := lb.PoolChanged()
+		tp.evict(lb.GetServers())
+
+		select {
+		case <-done:
+			return
+		case <-changed:
+		}
+	}
+}
+
+// dropping the transports of servers that are not in the pool and closing
+// their idle connections, requests in flight on them complete
+func (tp *transportPool) evict(servers []*balancer.Server) {
+	current := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		current[server.Address] = true
+	}
+
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+
+	for address, transport := range tp.transports {
+		if !current[address] {
+			transport.CloseIdleConnections()
+			delete(tp.transports, address)
+		}
+	}
+}
+
+// closing idle connections of every backend
+func (tp *transportPool) closeIdleConnections() {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+
+	for _, transport := range tp.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// stopping the eviction and closing idle connections of every backend
+func (tp *transportPool) close() {
+	tp.mutex.Lock()
+	if tp.done != nil {
+		close(tp.done)
+		tp.done = nil
+	}
+	tp.mutex.Unlock()
+
+	tp.closeIdleConnections()
+}
+
+// building the backend url, keeping the full client path and query
+func targetURL(base, in *url.URL) *url.URL {
+	target := *base
+	target.Path = singleJoiningSlash(base.Path, in.Path)
+	target.RawPath = ""
+	if in.RawPath != "" {
+		target.RawPath = singleJoiningSlash(base.EscapedPath(), in.EscapedPath())
+	}
+
+	if base.RawQuery == "" || in.RawQuery == "" {
+		target.RawQuery = base.RawQuery + in.RawQuery
+	} else {
+		target.RawQuery = base.RawQuery + "&" + in.RawQuery
+	}
+	return &target
+}
+
+// joining the backend base path and the request path with exactly one slash
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash && b != "":
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SusheelSathyaraj/go-load-balancer/balancer"
+)
+
+// limits for upgraded connections such as websockets
+type TunnelConfig struct {
+	IdleTimeout time.Duration //close the tunnel after no data in either direction, 0 means no limit
+	MaxDuration time.Duration //close the tunnel after this long regardless of traffic, 0 means no limit
+}
+
+// getting the protocol the client wants to upgrade to, empty for ordinary requests
+func upgradeType(h http.Header) string {
+	for _, value := range h.Values("Connection") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(textproto.TrimString(field), "upgrade") {
+				return h.Get("Upgrade")
+			}
+		}
+	}
+	return ""
+}
+
+// tunnelling a 101 Switching Protocols response, the client connection is hijacked
+// and bytes are piped both ways until either side closes or a limit is hit
+func (p *Proxy) handleUpgradeResponse(w http.ResponseWriter, resp *http.Response, requested string, server *balancer.Server) {
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), requested) {
+		http.Error(w, "backend switched to an unexpected protocol", http.StatusBadGateway)
+		log.Printf("Warning: backend %s switched to %q, client requested %q", server.Address, resp.Header.Get("Upgrade"), requested)
+		return
+	}
+
+	backConn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		http.Error(w, "backend connection does not support upgrades", http.StatusBadGateway)
+		return
+	}
+	defer backConn.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection does not support upgrades", http.StatusInternalServerError)
+		return
+	}
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error: failed to hijack connection for %s upgrade: %v", requested, err)
+		return
+	}
+	defer clientConn.Close()
+
+	//writing the 101 response with the upgrade headers restored
+	removeHopByHopHeaders(resp.Header)
+	resp.Header.Set("Connection", "Upgrade")
+	resp.Header.Set("Upgrade", requested)
+	appendVia(resp.Header, resp.ProtoMajor, resp.ProtoMinor, p.Forwarding.ViaPseudonym)
+	resp.Body = nil
+	if err := resp.Write(clientBuf); err != nil {
+		log.Printf("Error: failed to write %s upgrade response: %v", requested, err)
+		return
+	}
+	if err := clientBuf.Flush(); err != nil {
+		log.Printf("Error: failed to write %s upgrade response: %v", requested, err)
+		return
+	}
+
+	var closeOnce sync.Once
+	closeBoth := func() {
+		closeOnce.Do(func() {
+			clientConn.Close()
+			backConn.Close()
+		})
+	}
+
+	//any traffic pushes the idle timer back
+	touch := func() {}
+	if p.Tunnel.IdleTimeout > 0 {
+		idleTimer := time.AfterFunc(p.Tunnel.IdleTimeout, closeBoth)
+		defer idleTimer.Stop()
+		touch = func() { idleTimer.Reset(p.Tunnel.IdleTimeout) }
+	}
+	if p.Tunnel.MaxDuration > 0 {
+		maxTimer := time.AfterFunc(p.Tunnel.MaxDuration, closeBoth)
+		defer maxTimer.Stop()
+	}
+
+	log.Printf("Tunnelling %s connection to %s", requested, server.Address)
+
+	errc := make(chan error, 2)
+	go func() {
+		//reading through the buffer so bytes the client sent early are not lost
+		_, err := io.Copy(backConn, activityReader{clientBuf, touch})
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(clientConn, activityReader{backConn, touch})
+		errc <- err
+	}()
+
+	<-errc
+	closeBoth()
+	log.Printf("Closed %s connection to %s", requested, server.Address)
+}
+
+// reader that reports every successful read, used for idle tracking
+type activityReader struct {
+	r      io.Reader
+	active func()
+}
+
+func (ar activityReader) Read(b []byte) (int, error) {
+	n, err := ar.r.Read(b)
+	if n > 0 {
+		ar.active()
+	}
+	return n, err
+}